@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/prometheus/exporter-toolkit/web"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"yunche.pro/dtsre/oracledb_exporter/collector"
+)
+
+var (
+	checkCmd = kingpin.Command("check-config", "Check the config file and exit, with a non-zero status if the check fails.")
+
+	checkConnect = checkCmd.Flag(
+		"connect",
+		"Connect to each target and its PDBs.",
+	).Bool()
+	checkRunCollectors = checkCmd.Flag(
+		"run-collectors",
+		"Run each enabled collector once in each container, implies --connect.",
+	).Bool()
+	checkTimeout = checkCmd.Flag(
+		"timeout",
+		"Timeout of the checks of a target.",
+	).Default("1m").Duration()
+)
+
+// checkConfig strictly loads the config file, optionally connects to the targets
+// and runs the collectors, and reports the result of each step to w.
+func checkConfig(w io.Writer) bool {
+	cfg, err := collector.LoadConfigStrict(*configFile)
+	if err == nil {
+		err = cfg.ValidateCollectors(builtinScrapers())
+	}
+	if err != nil {
+		fmt.Fprintf(w, "config %s: FAIL %s\n", *configFile, err)
+		return false
+	}
+	fmt.Fprintf(w, "config %s: OK\n", *configFile)
+	if *webConfigFile != "" {
+		if err := web.Validate(*webConfigFile); err != nil {
+			fmt.Fprintf(w, "web config %s: FAIL %s\n", *webConfigFile, err)
+			return false
+		}
+		fmt.Fprintf(w, "web config %s: OK\n", *webConfigFile)
+	}
+
+	if !*checkConnect && !*checkRunCollectors {
+		return true
+	}
+
+	scrapers := checkScrapers(cfg)
+	ok := true
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tPDB\tCOLLECTOR\tRESULT")
+	for _, dbConfig := range cfg.Databases() {
+		target := collector.NewTarget(dbConfig.Name, dbConfig)
+		ctx, cancel := context.WithTimeout(context.Background(), *checkTimeout)
+		results := collector.Check(ctx, target, scrapers, *checkRunCollectors)
+		cancel()
+		target.Close()
+
+		for _, r := range results {
+			result := "OK"
+			switch {
+			case r.Err != nil:
+				result = "FAIL " + r.Err.Error()
+				ok = false
+			case r.Skipped != "":
+				result = "SKIPPED " + r.Skipped
+			case r.BadRows != nil:
+				result = "WARN " + r.BadRows.Error()
+			}
+			name := r.Target
+			if name == "" {
+				name = "default"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, r.Pdb, r.Collector, result)
+		}
+	}
+	tw.Flush()
+	return ok
+}
+
+// checkScrapers returns the scrapers enabled on any endpoint.
+func checkScrapers(cfg *collector.Config) []collector.Scraper {
+	seen := make(map[string]bool)
+	var ret []collector.Scraper
+	for _, ep := range endpoints(cfg) {
+		scrapers, err := endpointScrapers(cfg, ep.Name)
+		if err != nil {
+			continue
+		}
+		for _, scraper := range scrapers {
+			if !seen[scraper.Name()] {
+				seen[scraper.Name()] = true
+				ret = append(ret, scraper)
+			}
+		}
+	}
+	return ret
+}
+
+func runCheckConfig() {
+	if !checkConfig(os.Stdout) {
+		os.Exit(1)
+	}
+}
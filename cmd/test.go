@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 
 	"time"
@@ -9,6 +10,7 @@ import (
 	"context"
 	"sync"
 
+	"gopkg.in/yaml.v2"
 	"yunche.pro/dtsre/oracledb_exporter/dbutil"
 )
 
@@ -16,9 +18,22 @@ var (
 	configFile = "config.yaml"
 )
 
+func newClient() *dbutil.OracleClient {
+	var c dbutil.OracleConfig
+	buf, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		fmt.Printf("Read config error: %s\n", err)
+	}
+	err = yaml.Unmarshal(buf, &c)
+	if err != nil {
+		fmt.Printf("Parse config error: %s\n", err)
+	}
+	return dbutil.NewOracleClient(c)
+}
+
 func main() {
 
-	dbclient := dbutil.NewOracleClient(configFile)
+	dbclient := newClient()
 
 	var wg sync.WaitGroup
 
@@ -27,8 +42,9 @@ func main() {
 
 		defer wg.Done()
 		for i := 0; i < 100; i++ {
-			ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			executeWithTimeout(ctx, dbclient, 9999999)
+			cancel()
 			fmt.Printf("==== %d =====\n", i)
 			// time.Sleep(time.Millisecond)
 		}
@@ -39,8 +55,9 @@ func main() {
 	go func() {
 		defer wg.Done()
 		for i := 0; i < 100; i++ {
-			ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			executeWithTimeout(ctx, dbclient, 10000001)
+			cancel()
 			fmt.Printf("***** %d *****\n", i+100)
 			// time.Sleep(time.Millisecond)
 		}
@@ -73,7 +90,7 @@ func executeWithTimeout(ctx context.Context, dbclient *dbutil.OracleClient, idx
 }
 
 func executePrepared() {
-	dbclient := dbutil.NewOracleClient(configFile)
+	dbclient := newClient()
 	// sql := "select sql_id, sql_fulltext from v$sql where sql_id like :1 and rownum < :2"
 	// sql := "select sysdate, t.* from v$sql t where rownum  < :2"
 	sql := "select * from v$sqlarea where rownum < :1"
@@ -91,7 +108,7 @@ func executePrepared() {
 func executeQuery() {
 	path := os.Getenv("DYLD_LIBRARY_PATH")
 	fmt.Printf("Path: %s\n", path)
-	cli := dbutil.NewOracleClient(configFile)
+	cli := newClient()
 	db, err := cli.Connect()
 	if err != nil {
 		fmt.Printf("Connect error: %s\n", err)
@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
@@ -9,17 +10,50 @@ import (
 )
 
 var (
-	oracleActiveSessionDesc = prometheus.NewDesc(
+	oracleActiveSessionDesc = newInstanceDesc(
 		prometheus.BuildFQName(namespace, "session", "active"),
 		"Oracle Active Session",
-		[]string{"sid", "serial", "username", "sql_id", "sql_child_number", "program", "machine", "event", "sql_text", "con_id", "con_name"}, nil)
+		[]string{"sid", "serial", "username", "sql_id", "sql_child_number", "program", "machine", "event", "sql_text", "con_id", "con_name"})
 
-	oracleBlockingSessionDesc = prometheus.NewDesc(
+	oracleBlockingSessionDesc = newInstanceDesc(
 		prometheus.BuildFQName(namespace, "session", "blocking"),
 		"Oracle Blocking Session",
 		[]string{"sid", "serial", "logon_time", "status", "event", "p1", "p2", "p3", "username",
 			"terminal", "program", "sql_id", "prev_sql_id", "blocking_session", "blocking_instance",
-			"row_wait_obj", "sql_text", "prev_sql_text", "con_id", "con_name"}, nil)
+			"row_wait_obj", "sql_text", "prev_sql_text", "con_id", "con_name"})
+)
+
+// the sessions of all instances of a RAC, %s is the con_id column. A session is identified by inst_id and sid,
+// the sql of a session is in the v$sql of its instance.
+const (
+	racActiveSessionSql = `select * from (
+select
+    last_call_et, a.inst_id, a.sid, a.serial#, a.username, a.sql_id, a.sql_child_number, a.program, a.machine, a.event, b.sql_text, %s
+from gv$session a, gv$sql b
+where a.status = 'ACTIVE'
+  and a.inst_id = b.inst_id
+  and a.sql_id = b.sql_id
+  and rawtohex(sql_address) <> '00'
+  and a.username is not null
+  and a.type<>'BACKGROUND'
+  and not (a.inst_id = userenv('instance') and a.sid = (select sid from v$mystat where rownum = 1))
+  order by last_call_et desc) where rownum <= 30
+	`
+
+	racBlockingSessionSql = `with sessions as (
+select last_call_et, inst_id,
+  sid, serial# serial, to_char(logon_time, 'yyyy-mm-dd hh24:mi:ss') as logon_time, status, 
+  event,p1, p2,p3,username, terminal, program, sql_id, prev_sql_id,
+  blocking_session, blocking_instance, ROW_WAIT_OBJ# row_wait_obj, %s
+from gv$session )
+select a.*, b.sql_text, c.sql_text as prev_sql_text
+from sessions a left join gv$sql b 
+on a.inst_id = b.inst_id and a.sql_id = b.sql_id
+left join gv$sql c
+on a.inst_id = c.inst_id and a.prev_sql_id = c.sql_id
+where (a.inst_id, a.sid) in (select blocking_instance, blocking_session from sessions)
+     or blocking_session is not null
+	`
 )
 
 type ScrapeBlockSessionStat struct{}
@@ -37,21 +71,26 @@ func (ScrapeBlockSessionStat) Version() float64 {
 	return 10.2
 }
 
-func (s ScrapeBlockSessionStat) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	err := s.scrapeActiveSession(ctx, dbcli, ch, ora)
+func (ScrapeBlockSessionStat) Requirement() Requirement {
+	return Requirement{Mounted: true}
+}
+
+func (s ScrapeBlockSessionStat) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	out := newMetricSender(ch)
+	err := s.scrapeActiveSession(ctx, dbcli, out, ora)
 	if err != nil {
 		return err
 	}
 
-	err = s.scrapeBlockingSession(ctx, dbcli, ch, ora)
+	err = s.scrapeBlockingSession(ctx, dbcli, out, ora)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return out.err()
 }
 
-func (ScrapeBlockSessionStat) scrapeActiveSession(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+func (ScrapeBlockSessionStat) scrapeActiveSession(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
 	var sql string
 	if ora.VersionNum < 12.0 {
 		sql = `select * from (
@@ -80,32 +119,44 @@ where a.status = 'ACTIVE'
   order by last_call_et desc) where rownum <= 30
 	`
 	}
+	if ora.Rac {
+		conId := "a.con_id"
+		if ora.VersionNum < 12.0 {
+			conId = "0 as con_id"
+		}
+		sql = fmt.Sprintf(racActiveSessionSql, conId)
+	}
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("Get Blocking Session has Error")
 		return err
 	}
-	//cols 1,2,5 float64
 	for _, r := range rows {
-		ch <- prometheus.MustNewConstMetric(
-			oracleActiveSessionDesc, prometheus.GaugeValue, r[0].(float64),
-			formatFloat64(r[1].(float64)),
-			formatFloat64(r[2].(float64)),
-			r[3].(string), r[4].(string),
-			formatFloat64(r[5].(float64)),
-			r[6].(string),
-			r[7].(string),
-			r[8].(string),
-			r[9].(string),
-			formatFloat64(r[10].(float64)),
+		lastCallEt := r.Float("last_call_et")
+		labels := instanceLabels(ora, r,
+			r.String("sid"),
+			r.String("serial#"),
+			r.String("username"),
+			r.String("sql_id"),
+			r.String("sql_child_number"),
+			r.String("program"),
+			r.String("machine"),
+			r.String("event"),
+			r.String("sql_text"),
+			r.String("con_id"),
 			ora.ConName,
 		)
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		out.send(oracleActiveSessionDesc.get(ora), prometheus.GaugeValue, lastCallEt, labels...)
 	}
 	return nil
 }
 
-func (ScrapeBlockSessionStat) scrapeBlockingSession(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+func (ScrapeBlockSessionStat) scrapeBlockingSession(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
 	var sql string
 	if ora.VersionNum < 12.0 {
 		sql = `with sessions as (
@@ -138,38 +189,48 @@ where a.sid in (select blocking_session from sessions)
      or blocking_session is not null
 	`
 	}
+	if ora.Rac {
+		conId := "con_id"
+		if ora.VersionNum < 12.0 {
+			conId = "0 as con_id"
+		}
+		sql = fmt.Sprintf(racBlockingSessionSql, conId)
+	}
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("Get Blocking Session has Error")
 		return err
 	}
-	//cols 1,2,14,15,16 float64
 	for _, r := range rows {
-		ch <- prometheus.MustNewConstMetric(
-			oracleBlockingSessionDesc, prometheus.GaugeValue, r[0].(float64),
-			formatFloat64(r[1].(float64)),
-			formatFloat64(r[2].(float64)),
-			r[3].(string),
-			r[4].(string),
-			r[5].(string),
-			formatFloat64(r[6].(float64)),
-			formatFloat64(r[7].(float64)),
-			formatFloat64(r[8].(float64)),
-			r[9].(string),
-			r[10].(string),
-			r[11].(string),
-			r[12].(string),
-			r[13].(string),
-			formatFloat64(r[14].(float64)),
-			formatFloat64(r[15].(float64)),
-			formatFloat64(r[16].(float64)),
-			r[18].(string),
-			r[19].(string),
-			formatFloat64(r[17].(float64)),
+		lastCallEt := r.Float("last_call_et")
+		labels := instanceLabels(ora, r,
+			r.String("sid"),
+			r.String("serial"),
+			r.String("logon_time"),
+			r.String("status"),
+			r.String("event"),
+			r.String("p1"),
+			r.String("p2"),
+			r.String("p3"),
+			r.String("username"),
+			r.String("terminal"),
+			r.String("program"),
+			r.String("sql_id"),
+			r.String("prev_sql_id"),
+			r.String("blocking_session"),
+			r.String("blocking_instance"),
+			r.String("row_wait_obj"),
+			r.String("sql_text"),
+			r.String("prev_sql_text"),
+			r.String("con_id"),
 			ora.ConName,
 		)
-
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		out.send(oracleBlockingSessionDesc.get(ora), prometheus.GaugeValue, lastCallEt, labels...)
 	}
 	return nil
 }
@@ -40,16 +40,21 @@ func (ScrapeActiveTransactionStat) Version() float64 {
 	return 10.2
 }
 
-func (s ScrapeActiveTransactionStat) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	err := s.scrapeActiveTransaction(ctx, dbcli, ch, ora)
+func (ScrapeActiveTransactionStat) Requirement() Requirement {
+	return Requirement{Mounted: true}
+}
+
+func (s ScrapeActiveTransactionStat) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	out := newMetricSender(ch)
+	err := s.scrapeActiveTransaction(ctx, dbcli, out, ora)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return out.err()
 }
 
-func (ScrapeActiveTransactionStat) scrapeActiveTransaction(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+func (ScrapeActiveTransactionStat) scrapeActiveTransaction(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
 	var sql string
 	if ora.VersionNum < 12.0 {
 		sql = `select 0 as con_id, b.sid, 
@@ -84,33 +89,37 @@ and (sysdate - a.start_date) * 86400 >= 60
 	`
 	}
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("Get Transaction has Error")
 		return err
 	}
 
 	for _, r := range rows {
-		conId := formatFloat64(r[0].(float64))
-		sid := formatFloat64(r[1].(float64))
-		serial := formatFloat64(r[2].(float64))
-		sessionStatus := r[3].(string)
-		sqlId := r[4].(string)
-		prevSqlId := r[5].(string)
-		startTime := r[6].(string)
-		// transactionStatus := r[7].(string)
-		duration := r[8].(float64)
-		usedBlk := r[9].(float64)
-		usedRec := r[10].(float64)
-		ch <- prometheus.MustNewConstMetric(
+		row := out.row()
+		conId := r.String("con_id")
+		sid := r.String("sid")
+		serial := r.String("serial#")
+		sessionStatus := r.String("session_status")
+		sqlId := r.String("sql_id")
+		prevSqlId := r.String("prev_sql_id")
+		startTime := r.String("start_time")
+		duration := r.Float("duration")
+		usedBlk := r.Float("used_ublk")
+		usedRec := r.Float("used_urec")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		row.send(
 			oracleActiveTransactionDurationDesc, prometheus.GaugeValue, duration,
 			conId, sid, serial, sessionStatus, sqlId, prevSqlId, startTime,
 		)
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleActiveTransactionUndoBlkDesc, prometheus.GaugeValue, usedBlk,
 			conId, sid, serial, sessionStatus, sqlId, prevSqlId, startTime,
 		)
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleActiveTransactionUndoRecDesc, prometheus.GaugeValue, usedRec,
 			conId, sid, serial, sessionStatus, sqlId, prevSqlId, startTime,
 		)
@@ -28,42 +28,49 @@ func (ScrapeOracleAsmStat) Version() float64 {
 	return 10.2
 }
 
-func (ScrapeOracleAsmStat) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+// v$asm_diskgroup_stat has no container, scrape it once in the root
+func (ScrapeOracleAsmStat) Requirement() Requirement {
+	return Requirement{Container: ContainerRoot, Mounted: true}
+}
+
+func (ScrapeOracleAsmStat) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 
-	diskGroups, err := getAsmDiskgroup(ctx, dbcli)
+	out := newMetricSender(ch)
+	diskGroups, err := getAsmDiskgroup(ctx, dbcli, out)
 	if err != nil {
 		return err
 	}
 	for _, r := range diskGroups {
-		ch <- prometheus.MustNewConstMetric(
+		row := out.row()
+		row.send(
 			oracleAsmInfoDesc, prometheus.GaugeValue, r.spaceTotal,
 			r.groupName, r.state, r.groupType, "total")
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleAsmInfoDesc, prometheus.GaugeValue, r.spaceFree,
 			r.groupName, r.state, r.groupType, "free")
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleAsmInfoDesc, prometheus.GaugeValue, r.spaceUsed,
 			r.groupName, r.state, r.groupType, "used")
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleAsmInfoDesc, prometheus.GaugeValue, r.spaceUsedPct,
 			r.groupName, r.state, r.groupType, "used_pct")
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleAsmInfoDesc, prometheus.GaugeValue, r.requiredMirrorFree,
 			r.groupName, r.state, r.groupType, "required_mirror_free")
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleAsmInfoDesc, prometheus.GaugeValue, r.useablFileMb,
 			r.groupName, r.state, r.groupType, "useable_file_mb")
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleAsmInfoDesc, prometheus.GaugeValue, r.offlineDisks,
 			r.groupName, r.state, r.groupType, "offline_disks")
 	}
-	return nil
+	return out.err()
 }
 
 type AsmDiskgroupStat struct {
@@ -79,7 +86,8 @@ type AsmDiskgroupStat struct {
 	spaceUsedPct       float64
 }
 
-func getAsmDiskgroup(ctx context.Context, dbcli *dbutil.OracleClient) ([]AsmDiskgroupStat, error) {
+// getAsmDiskgroup returns the diskgroups, rows which can not be read are skipped by out.
+func getAsmDiskgroup(ctx context.Context, dbcli dbutil.Querier, out *metricSender) ([]AsmDiskgroupStat, error) {
 	sql := `select  name as group_name,
     state,
     type,
@@ -90,7 +98,7 @@ func getAsmDiskgroup(ctx context.Context, dbcli *dbutil.OracleClient) ([]AsmDisk
     usable_file_mb,
     offline_disks
 from v$asm_diskgroup_stat`
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
@@ -99,15 +107,19 @@ from v$asm_diskgroup_stat`
 
 	for _, r := range rows {
 		group := AsmDiskgroupStat{
-			groupName:          r[0].(string),
-			state:              r[1].(string),
-			groupType:          r[2].(string),
-			spaceTotal:         r[3].(float64),
-			spaceFree:          r[4].(float64),
-			spaceUsed:          r[5].(float64),
-			requiredMirrorFree: r[6].(float64),
-			useablFileMb:       r[7].(float64),
-			offlineDisks:       r[8].(float64),
+			groupName:          r.String("group_name"),
+			state:              r.String("state"),
+			groupType:          r.String("type"),
+			spaceTotal:         r.Float("space_total"),
+			spaceFree:          r.Float("space_free"),
+			spaceUsed:          r.Float("space_used"),
+			requiredMirrorFree: r.Float("required_mirror_free_mb"),
+			useablFileMb:       r.Float("usable_file_mb"),
+			offlineDisks:       r.Float("offline_disks"),
+		}
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
 		}
 		group.spaceUsedPct = 100.0 * group.spaceUsed / group.spaceTotal
 		result = append(result, group)
@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"testing"
+
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+func TestScrapeOracleAsmStat(t *testing.T) {
+	q := dbutil.NewFakeQuerier().
+		Add(`from v\$asm_diskgroup_stat`, []string{"group_name", "state", "type", "space_total", "space_free",
+			"space_used", "required_mirror_free_mb", "usable_file_mb", "offline_disks"},
+			dbutil.Row{"DATA", "CONNECTED", "EXTERN", 1000.0, 250.0, 750.0, 0.0, 250.0, 0.0})
+
+	testScrape(t, ScrapeOracleAsmStat{}, q, &InstanceInfoAll{}, `
+# HELP oracle_asm_diskgroup_stat Oracle Asm Diskgrup Stats
+# TYPE oracle_asm_diskgroup_stat gauge
+oracle_asm_diskgroup_stat{group_name="DATA",mode="free",state="CONNECTED",type="EXTERN"} 250
+oracle_asm_diskgroup_stat{group_name="DATA",mode="offline_disks",state="CONNECTED",type="EXTERN"} 0
+oracle_asm_diskgroup_stat{group_name="DATA",mode="required_mirror_free",state="CONNECTED",type="EXTERN"} 0
+oracle_asm_diskgroup_stat{group_name="DATA",mode="total",state="CONNECTED",type="EXTERN"} 1000
+oracle_asm_diskgroup_stat{group_name="DATA",mode="useable_file_mb",state="CONNECTED",type="EXTERN"} 250
+oracle_asm_diskgroup_stat{group_name="DATA",mode="used",state="CONNECTED",type="EXTERN"} 750
+oracle_asm_diskgroup_stat{group_name="DATA",mode="used_pct",state="CONNECTED",type="EXTERN"} 75
+`)
+}
@@ -2,10 +2,8 @@ package collector
 
 import (
 	"context"
-	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 	"yunche.pro/dtsre/oracledb_exporter/dbutil"
 )
 
@@ -16,54 +14,32 @@ var (
 		[]string{"bs_key", "recid", "stamp", "start_time", "completion_time", "backup_type", "con_id", "con_name"}, nil)
 )
 
-type ScrapeOracleBackupInfo struct {
-	lastSnapshotId float64
-	lastScrapeTime map[string]time.Time
-}
+type ScrapeOracleBackupInfo struct{}
 
-func (*ScrapeOracleBackupInfo) Name() string {
+func (ScrapeOracleBackupInfo) Name() string {
 	return "oracle_backup_set"
 }
 
-func (*ScrapeOracleBackupInfo) Help() string {
+func (ScrapeOracleBackupInfo) Help() string {
 	return "collect backup set information"
 }
 
-func (*ScrapeOracleBackupInfo) Version() float64 {
+func (ScrapeOracleBackupInfo) Version() float64 {
 	return 10.2
 }
 
-func (s *ScrapeOracleBackupInfo) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	if s.lastScrapeTime == nil {
-		s.lastScrapeTime = make(map[string]time.Time)
-	}
-
-	if lastTime, ok := s.lastScrapeTime[ora.ConId]; ok {
-		duration := time.Since(lastTime)
-
-		if duration < ScrapeIntervalSnapshot {
-			log.WithFields(log.Fields{"last_scrape_time": s.lastScrapeTime, "scrape_interval": ScrapeIntervalSnapshot}).Info("skip scape")
-			return nil
-		}
-	}
-
-	err := s.scrape(ctx, dbcli, ch, ora)
-	if err != nil {
-		return err
-	}
-
-	s.lastScrapeTime[ora.ConId] = time.Now()
-	return nil
+func (ScrapeOracleBackupInfo) Requirement() Requirement {
+	return Requirement{Mounted: true}
 }
 
-func (s *ScrapeOracleBackupInfo) scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+func (ScrapeOracleBackupInfo) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 	var sql string
 	if ora.VersionNum < 12.0 {
 		sql = `select bs_key, 
     recid, 
     stamp,
-    to_char(start_time, 'yyyy-mm-dd hh24:mi:ss'),
-    to_char(completion_time, 'yyyy-mm-dd hh24:mi:ss'),
+    to_char(start_time, 'yyyy-mm-dd hh24:mi:ss') as start_time,
+    to_char(completion_time, 'yyyy-mm-dd hh24:mi:ss') as completion_time,
     elapsed_seconds, 
     output_bytes,
     backup_type,
@@ -74,8 +50,8 @@ from v$backup_set_details`
     bs_key, 
     recid,
     stamp,
-    to_char(start_time, 'yyyy-mm-dd hh24:mi:ss'),
-    to_char(completion_time, 'yyyy-mm-dd hh24:mi:ss'),
+    to_char(start_time, 'yyyy-mm-dd hh24:mi:ss') as start_time,
+    to_char(completion_time, 'yyyy-mm-dd hh24:mi:ss') as completion_time,
     elapsed_seconds,
     output_bytes,
     backup_type,
@@ -83,23 +59,29 @@ from v$backup_set_details`
 from v$backup_set_details`
 	}
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return err
 	}
 
+	out := newMetricSender(ch)
 	for _, r := range rows {
-		ch <- prometheus.MustNewConstMetric(
-			oracleBackupInfoDesc, prometheus.GaugeValue, r[6].(float64),
-			formatFloat64(r[0].(float64)),
-			formatFloat64(r[1].(float64)),
-			formatFloat64(r[2].(float64)),
-			r[3].(string),
-			r[4].(string),
-			r[7].(string),
-			formatFloat64(r[8].(float64)),
+		size := r.Float("output_bytes")
+		labels := []string{
+			r.String("bs_key"),
+			r.String("recid"),
+			r.String("stamp"),
+			r.String("start_time"),
+			r.String("completion_time"),
+			r.String("backup_type"),
+			r.String("con_id"),
 			ora.ConName,
-		)
+		}
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		out.send(oracleBackupInfoDesc, prometheus.GaugeValue, size, labels...)
 	}
-	return nil
+	return out.err()
 }
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BadRowsError is returned by a scraper which skipped rows it could not read, or whose metrics
+// are invalid, e.g. with a label value which is not UTF-8. The metrics of the other rows are sent.
+type BadRowsError struct {
+	Rows int
+	// Err is the error of the last bad row
+	Err error
+}
+
+func (e *BadRowsError) Error() string {
+	return fmt.Sprintf("%d bad rows skipped, last: %s", e.Rows, e.Err)
+}
+
+func (e *BadRowsError) Unwrap() error {
+	return e.Err
+}
+
+// metricSender sends the metrics of the rows of a scrape. Instead of panicking
+// like prometheus.MustNewConstMetric, bad rows are skipped and counted.
+type metricSender struct {
+	ch  chan<- prometheus.Metric
+	bad BadRowsError
+}
+
+func newMetricSender(ch chan<- prometheus.Metric) *metricSender {
+	return &metricSender{ch: ch}
+}
+
+// send sends the metric, a metric which can not be created counts as bad row.
+func (s *metricSender) send(desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	m, err := prometheus.NewConstMetric(desc, valueType, value, labelValues...)
+	if err != nil {
+		s.skip(err)
+		return
+	}
+	s.ch <- m
+}
+
+// row returns a sender of the metrics of one row, for scrapers sending several metrics per row:
+// the row counts once however many of its metrics can not be created.
+func (s *metricSender) row() *rowSender {
+	return &rowSender{out: s}
+}
+
+// skip counts a row which could not be read.
+func (s *metricSender) skip(err error) {
+	s.bad.Rows++
+	s.bad.Err = err
+}
+
+// err returns a *BadRowsError if rows were skipped.
+func (s *metricSender) err() error {
+	if s.bad.Rows == 0 {
+		return nil
+	}
+	bad := s.bad
+	return &bad
+}
+
+// rowSender sends the metrics of one row of a metricSender.
+type rowSender struct {
+	out *metricSender
+	bad bool
+}
+
+// send sends the metric, the row counts as bad row on the first metric which can not be created.
+func (r *rowSender) send(desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	m, err := prometheus.NewConstMetric(desc, valueType, value, labelValues...)
+	if err != nil {
+		if r.bad {
+			r.out.bad.Err = err
+			return
+		}
+		r.bad = true
+		r.out.skip(err)
+		return
+	}
+	r.out.ch <- m
+}
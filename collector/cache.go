@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// scrapeCache keeps the metrics of the last scrape of each collector and container,
+// and serves them again until the scrape interval of the collector has passed.
+type scrapeCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	// mu is held while the entry is refreshed, so concurrent scrapes wait for the result
+	mu        sync.Mutex
+	metrics   []prometheus.Metric
+	updatedAt time.Time
+}
+
+func newScrapeCache() *scrapeCache {
+	return &scrapeCache{entries: make(map[string]*cacheEntry)}
+}
+
+func (c *scrapeCache) entry(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cacheEntry{}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// scrape runs the scraper if the cached metrics are older than interval, otherwise sends the cached metrics.
+// Metrics of a failed scrape are sent but not cached, a scrape which only skipped bad rows is cached.
+func (c *scrapeCache) scrape(ctx context.Context, scraper Scraper, interval time.Duration, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	if interval <= 0 {
+		return scraper.Scrape(ctx, dbcli, ch, ora)
+	}
+
+	e := c.entry(scraper.Name() + "/" + ora.ConId)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.updatedAt.IsZero() && time.Since(e.updatedAt) < interval {
+		log.WithFields(log.Fields{"collector": scraper.Name(), "pdb": ora.ConName, "last_scrape_time": e.updatedAt, "scrape_interval": interval}).Debug("serve cached metrics")
+		for _, m := range e.metrics {
+			ch <- m
+		}
+		return nil
+	}
+
+	metrics, err := collectMetrics(func(ch chan<- prometheus.Metric) error {
+		return scraper.Scrape(ctx, dbcli, ch, ora)
+	})
+	for _, m := range metrics {
+		ch <- m
+	}
+	var bad *BadRowsError
+	if err != nil && !errors.As(err, &bad) {
+		return err
+	}
+
+	e.metrics = metrics
+	e.updatedAt = time.Now()
+	return err
+}
+
+// collectMetrics buffers the metrics sent by scrape.
+func collectMetrics(scrape func(ch chan<- prometheus.Metric) error) ([]prometheus.Metric, error) {
+	var metrics []prometheus.Metric
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		close(done)
+	}()
+
+	err := scrape(ch)
+	close(ch)
+	<-done
+	return metrics, err
+}
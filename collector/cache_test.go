@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+var testCounterDesc = prometheus.NewDesc("oracle_test_counter", "test", nil, nil)
+
+type countingScraper struct {
+	calls int
+	err   error
+}
+
+func (*countingScraper) Name() string     { return "oracle_test" }
+func (*countingScraper) Help() string     { return "test scraper" }
+func (*countingScraper) Version() float64 { return 10.2 }
+
+func (s *countingScraper) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	s.calls++
+	ch <- prometheus.MustNewConstMetric(testCounterDesc, prometheus.GaugeValue, float64(s.calls))
+	return s.err
+}
+
+func scrapeCached(t *testing.T, c *scrapeCache, s Scraper, interval time.Duration) error {
+	metrics, err := collectMetrics(func(ch chan<- prometheus.Metric) error {
+		return c.scrape(context.Background(), s, interval, nil, ch, &InstanceInfoAll{})
+	})
+	if len(metrics) != 1 {
+		t.Fatalf("got %d metrics, want 1", len(metrics))
+	}
+	return err
+}
+
+func TestScrapeCache(t *testing.T) {
+	c := newScrapeCache()
+	s := &countingScraper{}
+
+	scrapeCached(t, c, s, time.Hour)
+	scrapeCached(t, c, s, time.Hour)
+	if s.calls != 1 {
+		t.Fatalf("cached metrics not served, scraper called %d times", s.calls)
+	}
+
+	scrapeCached(t, c, s, 0)
+	if s.calls != 2 {
+		t.Fatalf("scraper not called without interval, called %d times", s.calls)
+	}
+}
+
+func TestScrapeCacheError(t *testing.T) {
+	c := newScrapeCache()
+	s := &countingScraper{err: errors.New("ORA-00942: table or view does not exist")}
+
+	if err := scrapeCached(t, c, s, time.Hour); err == nil {
+		t.Fatal("error not returned")
+	}
+	scrapeCached(t, c, s, time.Hour)
+	if s.calls != 2 {
+		t.Fatalf("failed scrape was cached, scraper called %d times", s.calls)
+	}
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// CheckResult is the outcome of one step of a target check.
+type CheckResult struct {
+	Target string
+	// Pdb is the PDB the step ran in, empty for the database given by the target config
+	Pdb string
+	// Collector is the collector name, or connection and instance_info for the connection steps
+	Collector string
+	// Skipped is the reason the collector does not apply to the container
+	Skipped string
+	// BadRows is set if the collector skipped rows, its other metrics are served like in a scrape
+	BadRows *BadRowsError
+	Err     error
+}
+
+// Check connects to the target and its PDBs the way a scrape does. With runCollectors set, each
+// applicable scraper is run once in each container and its metrics are discarded.
+func Check(ctx context.Context, t *Target, scrapers []Scraper, runCollectors bool) []CheckResult {
+	var results []CheckResult
+	add := func(pdb string, collector string, skipped string, err error) {
+		results = append(results, CheckResult{Target: t.Name, Pdb: pdb, Collector: collector, Skipped: skipped, Err: err})
+	}
+
+	check := func(dbcli *dbutil.OracleClient, pdb string) *InstanceInfoAll {
+		err := dbcli.Init(ctx)
+		add(pdb, connectionCollector, "", err)
+		if err != nil {
+			return nil
+		}
+
+		ora, err := getOracleInfoAll(ctx, dbcli)
+		add(pdb, instanceInfoCollector, "", err)
+		if err != nil {
+			return nil
+		}
+		ora.Rac = ora.ClusterDatabase && !t.Client.C.DisableRac
+
+		if !runCollectors {
+			return ora
+		}
+		for _, scraper := range scrapers {
+			if reason := skipReason(scraper, ora); reason != "" {
+				add(pdb, scraper.Name(), reason, nil)
+				continue
+			}
+			_, err := collectMetrics(func(ch chan<- prometheus.Metric) error {
+				return scraper.Scrape(ctx, dbcli, ch, ora)
+			})
+			var bad *BadRowsError
+			if errors.As(err, &bad) {
+				results = append(results, CheckResult{Target: t.Name, Pdb: pdb, Collector: scraper.Name(), BadRows: bad})
+				continue
+			}
+			add(pdb, scraper.Name(), "", err)
+		}
+		return ora
+	}
+
+	ora := check(t.Client, "")
+	if ora == nil || ora.VersionNum < 12 || ora.ConId != "1" || ora.OpenMode == openModeMounted {
+		return results
+	}
+
+	pdbs := t.Client.C.Pdbs
+	if t.Client.C.PdbDiscovery.Enabled {
+		var discovered []string
+		_, err := collectMetrics(func(ch chan<- prometheus.Metric) error {
+			var err error
+			discovered, err = discoverPdbs(ctx, t.Client, t.Client.C.PdbDiscovery, ch)
+			return err
+		})
+		add("", pdbDiscoveryCollector, "", err)
+		if err != nil && discovered == nil {
+			return results
+		}
+		pdbs = discovered
+	}
+	for _, pdb := range pdbs {
+		check(t.Client.WithPdb(pdb), pdb)
+	}
+	return results
+}
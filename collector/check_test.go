@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// badRowsScraper skips a bad row like a collector reading a NULL value.
+type badRowsScraper struct{}
+
+func (badRowsScraper) Name() string     { return "test_bad_rows" }
+func (badRowsScraper) Help() string     { return "test scraper" }
+func (badRowsScraper) Version() float64 { return 10.2 }
+
+func (badRowsScraper) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	out := newMetricSender(ch)
+	out.skip(errors.New("NULL value"))
+	return out.err()
+}
+
+func TestCheckBadRows(t *testing.T) {
+	base := filepath.Join("testdata", "11g_noncdb")
+	cfg, err := LoadConfig(base + ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := dbutil.LoadFixtures(base + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbutil.Replay(f)
+	defer dbutil.Replay(nil)
+
+	target := NewTarget("", cfg.OracleConfig)
+	defer target.Close()
+
+	results := Check(context.Background(), target, []Scraper{badRowsScraper{}}, true)
+	if len(results) != 3 {
+		t.Fatalf("got results %+v", results)
+	}
+	r := results[2]
+	if r.Collector != "test_bad_rows" || r.Err != nil || r.BadRows == nil || r.BadRows.Rows != 1 {
+		t.Fatalf("bad rows reported as %+v", r)
+	}
+}
@@ -1,18 +1,17 @@
 package collector
 
 import (
+	"context"
+	"errors"
 	"regexp"
 	"strconv"
 	"strings"
-
-	"io/ioutil"
-
-	"gopkg.in/yaml.v2"
 )
 
 var (
 	labelRemovePattern = regexp.MustCompile("[:()*/-]")
 	labelRemoveDup     = regexp.MustCompile("  +")
+	errorCodePattern   = regexp.MustCompile(`\b(ORA|DPI|TNS|PLS)-\d+`)
 )
 
 func formatInList(params []string) string {
@@ -34,24 +33,6 @@ func formatFloat64(val float64) string {
 	return strconv.FormatFloat(val, 'f', 0, 64)
 }
 
-func loadContext() (map[string]string, error) {
-	var c = make(map[string]string)
-	buf, err := ioutil.ReadFile("context.yaml")
-	if err != nil {
-		// return empty map
-		return c, err
-	}
-
-	err = yaml.Unmarshal(buf, &c)
-	return c, err
-}
-
-func saveContext(c map[string]string) error {
-	out, err := yaml.Marshal(c)
-	ioutil.WriteFile("context.yaml", out, 0666)
-	return err
-}
-
 func parseVersion(vs string) (float64, error) {
 	elems := strings.Split(vs, ".")
 	prefix := len(elems)
@@ -61,3 +42,17 @@ func parseVersion(vs string) (float64, error) {
 	vv := strings.Join(elems[0:prefix], ".")
 	return strconv.ParseFloat(vv, 64)
 }
+
+// errorCode returns the Oracle error code of err, e.g. ORA-00942, to be used as label value.
+func errorCode(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return "timeout"
+	}
+	// godror reports client side errors as ORA-00000 followed by the DPI code
+	for _, code := range errorCodePattern.FindAllString(err.Error(), -1) {
+		if code != "ORA-00000" {
+			return code
+		}
+	}
+	return "unknown"
+}
@@ -1,6 +1,9 @@
 package collector
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -45,3 +48,18 @@ func TestFormatLabel(t *testing.T) {
 	}
 
 }
+
+func TestErrorCode(t *testing.T) {
+	cases := map[string]error{
+		"ORA-00942": errors.New("ORA-00942: table or view does not exist"),
+		"DPI-1047":  errors.New("ORA-00000: DPI-1047: Cannot locate a 64-bit Oracle Client library"),
+		"timeout":   fmt.Errorf("query: %w", context.DeadlineExceeded),
+		"unknown":   errors.New("DB Connection is Nil"),
+	}
+
+	for code, err := range cases {
+		if got := errorCode(err); got != code {
+			t.Fatalf("%s: got %s, want %s", err, got, code)
+		}
+	}
+}
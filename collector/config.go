@@ -1,7 +1,16 @@
 package collector
 
 import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v2"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
 )
 
 // exporter default config
@@ -9,4 +18,388 @@ import (
 const (
 	ScrapeIntervalTablespace = 3600 * time.Second
 	ScrapeIntervalSnapshot   = 600 * time.Second
+	ScrapeIntervalAsm        = 300 * time.Second
+	ScrapeIntervalParameter  = 3600 * time.Second
+)
+
+// default minimum scrape interval of the expensive collectors, by collector name
+var defaultScrapeIntervals = map[string]time.Duration{
+	"oracle_tablespace":    ScrapeIntervalTablespace,
+	"oracle_sql_snapshot":  ScrapeIntervalSnapshot,
+	"oracle_backup_set":    ScrapeIntervalSnapshot,
+	"oracle_asm_diskgroup": ScrapeIntervalAsm,
+	"oracle_parameter":     ScrapeIntervalParameter,
+}
+
+// priorities of the collectors, the collectors of a container are scraped one after another from the
+// lowest priority, so that the cheap critical ones are done before the scrape timeout runs out
+const (
+	PriorityCritical  = 10
+	PriorityHigh      = 20
+	PriorityDefault   = 50
+	PriorityExpensive = 90
 )
+
+// default priority of the collectors, by collector name, PriorityDefault if not listed
+var defaultPriorities = map[string]int{
+	"oracle_instance_info":  PriorityCritical,
+	"oracle_stat":           PriorityCritical,
+	"oracle_wait_event":     PriorityCritical,
+	"oracle_time_model":     PriorityHigh,
+	"oracle_memory_info":    PriorityHigh,
+	"oracle_os_stat":        PriorityHigh,
+	"oracle_active_session": PriorityHigh,
+	"oracle_rac":            PriorityHigh,
+	"oracle_tablespace":     PriorityExpensive,
+	"oracle_sql_snapshot":   PriorityExpensive,
+	"oracle_sql_stat":       PriorityExpensive,
+	"oracle_backup_set":     PriorityExpensive,
+	"oracle_asm_diskgroup":  PriorityExpensive,
+}
+
+// Config is the content of the exporter config file.
+// The top level database settings are the default target served on the telemetry path,
+// targets lists the named databases which can be scraped through the probe endpoint.
+type Config struct {
+	dbutil.OracleConfig `yaml:",inline"`
+	Targets             []dbutil.OracleConfig      `yaml:"targets"`
+	Collectors          map[string]CollectorConfig `yaml:"collectors"`
+	// CustomMetricsFile is the file defining custom metrics by sql
+	CustomMetricsFile string          `yaml:"customMetrics"`
+	CustomMetrics     []*CustomMetric `yaml:"-"`
+	// Endpoints are the http paths serving metrics, each with its own collectors
+	Endpoints []Endpoint `yaml:"endpoints"`
+	// State is where the stateful collectors save their state
+	State StateConfig `yaml:"state"`
+}
+
+// default state config
+const (
+	defaultStateDir = "state"
+	defaultStateTTL = 24 * time.Hour
+)
+
+// StateConfig configures the store of the state of the stateful collectors, e.g. the AWR snapshots already scraped.
+type StateConfig struct {
+	// Dir is the directory of the state files, one for each target and collector
+	Dir string `yaml:"dir"`
+	// TTL is the time the processed keys are kept
+	TTL time.Duration `yaml:"ttl"`
+}
+
+func (c StateConfig) GetDir() string {
+	if c.Dir != "" {
+		return c.Dir
+	}
+	return defaultStateDir
+}
+
+func (c StateConfig) GetTTL() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+	return defaultStateTTL
+}
+
+// Endpoint is a http path serving the metrics of the listed collectors,
+// so that cheap and expensive metrics can be scraped at different frequencies.
+type Endpoint struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	// Collectors are the collector names, the collectors enabled by flags if empty
+	Collectors []string `yaml:"collectors"`
+}
+
+// CollectorConfig holds the settings of a collector, the collectors section of the config file is keyed by collector name.
+type CollectorConfig struct {
+	// Interval is the minimum time between two scrapes of the collector, the metrics
+	// of the last scrape are served in between. 0 scrapes the collector every time.
+	Interval *time.Duration `yaml:"interval"`
+	// Enabled overrides the --collect.<name> flag of the collector, custom metrics are enabled by default
+	Enabled *bool `yaml:"enabled"`
+	// Timeout cancels the running query of the collector after this time in each container,
+	// 0 is only limited by the scrape timeout
+	Timeout *time.Duration `yaml:"timeout"`
+	// Priority orders the collectors of a container, lower priorities are scraped first
+	Priority *int `yaml:"priority"`
+}
+
+func LoadConfig(configFile string) (*Config, error) {
+	return loadConfig(configFile, yaml.Unmarshal)
+}
+
+// LoadConfigStrict loads the config file like LoadConfig, but rejects unknown keys
+// and databases missing the settings to connect.
+func LoadConfigStrict(configFile string) (*Config, error) {
+	c, err := loadConfig(configFile, yaml.UnmarshalStrict)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.checkRequired()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func loadConfig(configFile string, unmarshal func([]byte, interface{}) error) (*Config, error) {
+	buf, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Config{}
+	err = unmarshal(buf, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.expandEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.CustomMetricsFile != "" {
+		c.CustomMetrics, err = loadCustomMetrics(c.CustomMetricsFile, unmarshal)
+		if err != nil {
+			return nil, fmt.Errorf("load custom metrics %s: %s", c.CustomMetricsFile, err)
+		}
+	}
+	return &c, nil
+}
+
+// Databases returns the database configs of the targets, including the top level
+// database unless it is left empty and targets are configured.
+func (c *Config) Databases() []dbutil.OracleConfig {
+	if !c.hasDefaultDatabase() {
+		return c.Targets
+	}
+	return append([]dbutil.OracleConfig{c.OracleConfig}, c.Targets...)
+}
+
+// hasDefaultDatabase reports whether the top level database is monitored, it is not if it is
+// left empty and targets are configured.
+func (c *Config) hasDefaultDatabase() bool {
+	return len(c.Targets) == 0 || !reflect.DeepEqual(c.OracleConfig, dbutil.OracleConfig{})
+}
+
+// checkRequired checks that each database has the settings to connect.
+func (c *Config) checkRequired() error {
+	for _, t := range c.Databases() {
+		if t.Dsn == "" && (t.Host == "" || t.Port == 0 || (t.ServiceName == "" && t.Sid == "")) {
+			return fmt.Errorf("target %q: dsn or host, port and serviceName or sid are required", t.Name)
+		}
+		if t.ExternalAuth {
+			continue
+		}
+		if t.Username == "" {
+			return fmt.Errorf("target %q: username is required", t.Name)
+		}
+		if t.Password == "" && t.PasswordFile == "" {
+			return fmt.Errorf("target %q: password or passwordFile is required", t.Name)
+		}
+		if _, err := t.GetPassword(); err != nil {
+			return fmt.Errorf("target %q: %s", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// expandEnv expands the environment variables in the database configs.
+func (c *Config) expandEnv() error {
+	err := c.OracleConfig.ExpandEnv()
+	if err != nil {
+		return err
+	}
+	for i := range c.Targets {
+		err = c.Targets[i].ExpandEnv()
+		if err != nil {
+			return fmt.Errorf("targets[%d]: %s", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	names := make(map[string]bool)
+	for i, t := range c.Targets {
+		if t.Name == "" {
+			return fmt.Errorf("targets[%d]: name is required", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("targets[%d]: duplicate target name %q", i, t.Name)
+		}
+		names[t.Name] = true
+	}
+	if names[defaultStateKey] && c.hasDefaultDatabase() {
+		return fmt.Errorf("target name %q is reserved for the top level database", defaultStateKey)
+	}
+
+	for _, t := range append([]dbutil.OracleConfig{c.OracleConfig}, c.Targets...) {
+		err := validatePdbDiscovery(t.PdbDiscovery)
+		if err != nil {
+			return fmt.Errorf("target %q: pdbDiscovery: %s", t.Name, err)
+		}
+		if t.PdbConcurrency < 0 {
+			return fmt.Errorf("target %q: pdbConcurrency must not be negative", t.Name)
+		}
+		if t.CollectorConcurrency < 0 {
+			return fmt.Errorf("target %q: collectorConcurrency must not be negative", t.Name)
+		}
+		if t.MaxScrapesInFlight < 0 {
+			return fmt.Errorf("target %q: maxScrapesInFlight must not be negative", t.Name)
+		}
+		if t.Password != "" && t.PasswordFile != "" {
+			return fmt.Errorf("target %q: only one of password and passwordFile can be set", t.Name)
+		}
+		if t.ConnectTimeout < 0 {
+			return fmt.Errorf("target %q: connectTimeout must not be negative", t.Name)
+		}
+		if t.ScrapeTimeout < 0 {
+			return fmt.Errorf("target %q: scrapeTimeout must not be negative", t.Name)
+		}
+		if err := t.ValidateAdminRole(); err != nil {
+			return fmt.Errorf("target %q: %s", t.Name, err)
+		}
+		if _, err := t.GetTimezone(); err != nil {
+			return fmt.Errorf("target %q: timezone: %s", t.Name, err)
+		}
+		if t.ExternalAuth && (t.Username != "" || t.Password != "" || t.PasswordFile != "") {
+			return fmt.Errorf("target %q: externalAuth can not be used with username or password", t.Name)
+		}
+	}
+
+	endpointNames := make(map[string]bool)
+	endpointPaths := make(map[string]bool)
+	for i, ep := range c.Endpoints {
+		if ep.Name == "" {
+			return fmt.Errorf("endpoints[%d]: name is required", i)
+		}
+		if !strings.HasPrefix(ep.Path, "/") {
+			return fmt.Errorf("endpoints[%d]: path must start with /", i)
+		}
+		if endpointNames[ep.Name] || endpointPaths[ep.Path] {
+			return fmt.Errorf("endpoints[%d]: duplicate endpoint %q %s", i, ep.Name, ep.Path)
+		}
+		endpointNames[ep.Name] = true
+		endpointPaths[ep.Path] = true
+	}
+
+	if c.State.TTL < 0 {
+		return fmt.Errorf("state: ttl must not be negative")
+	}
+
+	for name, cc := range c.Collectors {
+		if cc.Interval != nil && *cc.Interval < 0 {
+			return fmt.Errorf("collectors.%s: interval must not be negative", name)
+		}
+		if cc.Timeout != nil && *cc.Timeout < 0 {
+			return fmt.Errorf("collectors.%s: timeout must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// ValidateCollectors checks that the collectors named in the endpoints and in the collectors settings are
+// the given scrapers or custom metrics, a typo would otherwise leave a collector silently unscraped.
+func (c *Config) ValidateCollectors(scrapers []Scraper) error {
+	known := make(map[string]bool)
+	for _, s := range append(scrapers, c.CustomScrapers()...) {
+		known[s.Name()] = true
+	}
+
+	for i, ep := range c.Endpoints {
+		for _, name := range ep.Collectors {
+			if !known[name] {
+				return fmt.Errorf("endpoints[%d]: unknown collector %q", i, name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(c.Collectors))
+	for name := range c.Collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("collectors: unknown collector %q", name)
+		}
+	}
+	return nil
+}
+
+func validatePdbDiscovery(d dbutil.PdbDiscoveryConfig) error {
+	for _, pattern := range []string{d.Include, d.Exclude} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Endpoint returns the named endpoint.
+func (c *Config) Endpoint(name string) (Endpoint, bool) {
+	for _, ep := range c.Endpoints {
+		if ep.Name == name {
+			return ep, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+// Target returns the database config of the named target.
+func (c *Config) Target(name string) (dbutil.OracleConfig, bool) {
+	for _, t := range c.Targets {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return dbutil.OracleConfig{}, false
+}
+
+// CollectorEnabled returns whether the named collector is enabled, def is the value of its flag.
+func (c *Config) CollectorEnabled(name string, def bool) bool {
+	if cc, ok := c.Collectors[name]; ok && cc.Enabled != nil {
+		return *cc.Enabled
+	}
+	return def
+}
+
+// ScrapeInterval returns the minimum time between two scrapes of the named collector.
+func (c *Config) ScrapeInterval(name string) time.Duration {
+	if cc, ok := c.Collectors[name]; ok && cc.Interval != nil {
+		return *cc.Interval
+	}
+	for _, m := range c.CustomMetrics {
+		if (ScrapeCustomMetric{Metric: m}).Name() == name {
+			return m.Interval
+		}
+	}
+	return defaultScrapeIntervals[name]
+}
+
+// ScrapeTimeout returns the time after which a scrape of the named collector is cancelled, 0 if not limited.
+func (c *Config) ScrapeTimeout(name string) time.Duration {
+	if cc, ok := c.Collectors[name]; ok && cc.Timeout != nil {
+		return *cc.Timeout
+	}
+	return 0
+}
+
+// ScrapePriority returns the priority of the named collector, lower priorities are scraped first.
+func (c *Config) ScrapePriority(name string) int {
+	if cc, ok := c.Collectors[name]; ok && cc.Priority != nil {
+		return *cc.Priority
+	}
+	if p, ok := defaultPriorities[name]; ok {
+		return p
+	}
+	return PriorityDefault
+}
@@ -0,0 +1,268 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	file := filepath.Join(dir, "oracledb_exporter.yaml")
+	err = ioutil.WriteFile(file, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadConfigTargets(t *testing.T) {
+	file := writeConfig(t, `
+host: 10.0.0.1
+port: 1521
+serviceName: orcl
+targets:
+  - name: db1
+    host: 10.0.0.2
+    port: 1522
+    username: monitor
+    password: secret
+    serviceName: cdb1
+    pdbs:
+      - pdb1
+`)
+	c, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.ServiceName != "orcl" {
+		t.Fatalf("default target serviceName: %q", c.ServiceName)
+	}
+
+	db1, ok := c.Target("db1")
+	if !ok {
+		t.Fatal("target db1 not found")
+	}
+	if db1.Host != "10.0.0.2" || db1.Port != 1522 || db1.ServiceName != "cdb1" || len(db1.Pdbs) != 1 {
+		t.Fatalf("unexpected target db1: %+v", db1)
+	}
+
+	if _, ok := c.Target("db2"); ok {
+		t.Fatal("unknown target db2 found")
+	}
+}
+
+func TestLoadConfigDuplicateTarget(t *testing.T) {
+	file := writeConfig(t, `
+targets:
+  - name: db1
+    host: 10.0.0.2
+  - name: db1
+    host: 10.0.0.3
+`)
+	_, err := LoadConfig(file)
+	if err == nil {
+		t.Fatal("duplicate target name not rejected")
+	}
+}
+
+func TestLoadConfigDefaultTargetName(t *testing.T) {
+	target := `
+targets:
+  - name: default
+    dsn: 10.0.0.2:1521/orcl
+    username: monitor
+    password: secret
+`
+	// the name is free if the top level database is not monitored
+	if _, err := LoadConfig(writeConfig(t, target)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadConfig(writeConfig(t, "dsn: 10.0.0.1:1521/orcl\nusername: monitor\npassword: secret\n"+target))
+	if err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Fatalf("target named default not rejected: %v", err)
+	}
+}
+
+func TestScrapeInterval(t *testing.T) {
+	file := writeConfig(t, `
+collectors:
+  oracle_tablespace:
+    interval: 10m
+  oracle_parameter:
+    interval: 0s
+`)
+	c, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]time.Duration{
+		"oracle_tablespace":    10 * time.Minute,
+		"oracle_parameter":     0,
+		"oracle_asm_diskgroup": ScrapeIntervalAsm,
+		"oracle_stat":          0,
+	}
+	for name, interval := range cases {
+		if got := c.ScrapeInterval(name); got != interval {
+			t.Fatalf("%s: got %s, want %s", name, got, interval)
+		}
+	}
+}
+
+func TestScrapeTimeoutPriority(t *testing.T) {
+	file := writeConfig(t, `
+collectors:
+  oracle_tablespace:
+    timeout: 20s
+    priority: 5
+`)
+	c, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.ScrapeTimeout("oracle_tablespace"); got != 20*time.Second {
+		t.Errorf("timeout of oracle_tablespace %s", got)
+	}
+	if got := c.ScrapeTimeout("oracle_stat"); got != 0 {
+		t.Errorf("timeout of oracle_stat %s", got)
+	}
+	cases := map[string]int{
+		"oracle_tablespace": 5,
+		"oracle_stat":       PriorityCritical,
+		"oracle_backup_set": PriorityExpensive,
+		"custom_sessions":   PriorityDefault,
+	}
+	for name, priority := range cases {
+		if got := c.ScrapePriority(name); got != priority {
+			t.Errorf("priority of %s: got %d, want %d", name, got, priority)
+		}
+	}
+
+	file = writeConfig(t, "collectors:\n  oracle_tablespace:\n    timeout: -1s\n")
+	if _, err := LoadConfig(file); err == nil {
+		t.Error("negative timeout accepted")
+	}
+}
+
+func TestCollectorEnabled(t *testing.T) {
+	file := writeConfig(t, `
+collectors:
+  oracle_sql_snapshot:
+    enabled: true
+  oracle_tablespace:
+    enabled: false
+  oracle_parameter:
+    interval: 1m
+`)
+	c, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		def  bool
+		want bool
+	}{
+		{"oracle_sql_snapshot", false, true},
+		{"oracle_tablespace", true, false},
+		{"oracle_parameter", true, true},
+		{"oracle_parameter", false, false},
+		{"oracle_asm_diskgroup", true, true},
+	} {
+		if got := c.CollectorEnabled(tc.name, tc.def); got != tc.want {
+			t.Errorf("CollectorEnabled(%s, %v) = %v, want %v", tc.name, tc.def, got, tc.want)
+		}
+	}
+}
+
+func TestLoadConfigEndpoints(t *testing.T) {
+	file := writeConfig(t, `
+endpoints:
+  - name: default
+    path: /metrics
+  - name: lowfreq
+    path: /lowfreq_metrics
+    collectors: [oracle_tablespace]
+`)
+	c, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep, ok := c.Endpoint("lowfreq")
+	if !ok || ep.Path != "/lowfreq_metrics" || len(ep.Collectors) != 1 {
+		t.Fatalf("unexpected endpoint lowfreq: %+v", ep)
+	}
+
+	file = writeConfig(t, `
+endpoints:
+  - name: default
+    path: /metrics
+  - name: lowfreq
+    path: /metrics
+`)
+	_, err = LoadConfig(file)
+	if err == nil {
+		t.Fatal("duplicate endpoint path not rejected")
+	}
+}
+
+func TestLoadConfigStrict(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		err     string
+	}{
+		{"dsn: 10.0.0.1:1521/orcl\nusername: monitor\npassword: secret\n", ""},
+		{"dsn: 10.0.0.1:1521/orcl\nusername: monitor\npassword: secret\nservice_name: orcl\n", "field service_name not found"},
+		{"host: 10.0.0.1\nport: 1521\nusername: monitor\npassword: secret\n", "dsn or host, port and serviceName or sid are required"},
+		{"dsn: 10.0.0.1:1521/orcl\npassword: secret\n", "username is required"},
+		{"targets:\n  - name: db1\n    dsn: 10.0.0.1:1521/orcl\n    username: monitor\n    password: secret\n", ""},
+		{"targets:\n  - name: db1\n    dsn: 10.0.0.1:1521/orcl\n    username: monitor\n", `target "db1": password or passwordFile is required`},
+	} {
+		_, err := LoadConfigStrict(writeConfig(t, tc.content))
+		if tc.err == "" && err != nil {
+			t.Errorf("%q: unexpected error %s", tc.content, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%q: error %v, want %q", tc.content, err, tc.err)
+		}
+	}
+}
+
+func TestValidateCollectors(t *testing.T) {
+	scrapers := []Scraper{ScrapeOracleStat{}, ScrapeOracleTablespaceStat{}}
+	metrics := writeConfig(t, "metrics:\n  - name: app_queue\n    sql: select depth from app.queues\n    values: {depth: \"\"}\n")
+	for _, tc := range []struct {
+		content string
+		err     string
+	}{
+		{"endpoints:\n  - name: lowfreq\n    path: /lowfreq_metrics\n    collectors: [oracle_tablespace]\n", ""},
+		{"collectors:\n  oracle_stat:\n    interval: 1m\n", ""},
+		{"customMetrics: " + metrics + "\ncollectors:\n  custom_app_queue:\n    interval: 1m\n", ""},
+		{"endpoints:\n  - name: lowfreq\n    path: /lowfreq_metrics\n    collectors: [oracle_tablespaces]\n", `endpoints[0]: unknown collector "oracle_tablespaces"`},
+		{"collectors:\n  oracle_stats:\n    interval: 1m\n", `collectors: unknown collector "oracle_stats"`},
+	} {
+		c, err := LoadConfig(writeConfig(t, tc.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.ValidateCollectors(scrapers)
+		if tc.err == "" && err != nil {
+			t.Errorf("%q: unexpected error %s", tc.content, err)
+		}
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("%q: error %v, want %q", tc.content, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// scope of a custom metric
+const (
+	scopeAll = "all"
+	scopeCdb = "cdb"
+	scopePdb = "pdb"
+)
+
+var (
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	metricTypes = map[string]prometheus.ValueType{
+		"gauge":   prometheus.GaugeValue,
+		"counter": prometheus.CounterValue,
+		"untyped": prometheus.UntypedValue,
+	}
+
+	// builtinSubsystems are the subsystems of the metrics of the exporter and its collectors, like oracle_stat_*,
+	// and oracle_up. A custom metric in one of them could have the name of a builtin metric and fail the whole scrape.
+	builtinSubsystems = []string{exporter, "up", "asm_diskgroup", "backupset", "instance", "osstat", "param", "pdb", "pga",
+		"rac", "recovery_area", "session", "sga", "sql", "stat", "tablespace", "time_model", "transaction", "wait"}
+)
+
+// CustomMetric is a metric defined by a query in the custom metrics file.
+// Each column in Values becomes the metric oracle_<name>_<column>, labeled by the columns in Labels.
+type CustomMetric struct {
+	Name string `yaml:"name"`
+	Help string `yaml:"help"`
+	Sql  string `yaml:"sql"`
+	// Labels are the column names used as labels
+	Labels []string `yaml:"labels"`
+	// Values maps the column names used as metric values to their help text
+	Values map[string]string `yaml:"values"`
+	// Type is one of gauge (default), counter or untyped
+	Type string `yaml:"type"`
+	// MinVersion is the minimum Oracle version the query works on, e.g. 12.1
+	MinVersion float64 `yaml:"minVersion"`
+	// Scope is where the query runs: cdb (CDB root or non-CDB), pdb or all (default)
+	Scope string `yaml:"scope"`
+	// Interval is the minimum time between two executions of the query
+	Interval time.Duration `yaml:"interval"`
+	// Mounted is set if the query only uses fixed v$ views and can run on a MOUNTED database
+	Mounted bool `yaml:"mounted"`
+
+	descs     map[string]*prometheus.Desc
+	valueType prometheus.ValueType
+}
+
+type customMetricsFile struct {
+	Metrics []*CustomMetric `yaml:"metrics"`
+}
+
+func loadCustomMetrics(file string, unmarshal func([]byte, interface{}) error) ([]*CustomMetric, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	c := customMetricsFile{}
+	err = unmarshal(buf, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	// the custom metric of each metric name, like oracle_app_queue_depth of app_queue
+	fqNames := make(map[string]string)
+	for i, m := range c.Metrics {
+		err = m.init()
+		if err != nil {
+			return nil, fmt.Errorf("metrics[%d]: %s", i, err)
+		}
+		if names[m.Name] {
+			return nil, fmt.Errorf("metrics[%d]: duplicate metric name %q", i, m.Name)
+		}
+		names[m.Name] = true
+
+		for col := range m.descs {
+			fqName := prometheus.BuildFQName(namespace, m.Name, col)
+			if isBuiltinMetric(fqName) {
+				return nil, fmt.Errorf("metrics[%d]: %s: metric %s is in the namespace of the builtin metrics", i, m.Name, fqName)
+			}
+			if other, ok := fqNames[fqName]; ok {
+				return nil, fmt.Errorf("metrics[%d]: %s: metric %s is also a metric of %s", i, m.Name, fqName, other)
+			}
+			fqNames[fqName] = m.Name
+		}
+	}
+	return c.Metrics, nil
+}
+
+// isBuiltinMetric returns whether the metric name is in the subsystem of builtin metrics.
+func isBuiltinMetric(fqName string) bool {
+	for _, subsystem := range builtinSubsystems {
+		if strings.HasPrefix(fqName, namespace+"_"+subsystem+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// init validates the metric and builds its descriptors.
+func (m *CustomMetric) init() error {
+	if !metricNamePattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid metric name %q", m.Name)
+	}
+	if m.Sql == "" {
+		return fmt.Errorf("%s: sql is required", m.Name)
+	}
+	if len(m.Values) == 0 {
+		return fmt.Errorf("%s: at least one value column is required", m.Name)
+	}
+
+	if m.Type == "" {
+		m.Type = "gauge"
+	}
+	valueType, ok := metricTypes[m.Type]
+	if !ok {
+		return fmt.Errorf("%s: unknown metric type %q", m.Name, m.Type)
+	}
+	m.valueType = valueType
+
+	if m.Scope == "" {
+		m.Scope = scopeAll
+	}
+	if m.Scope != scopeAll && m.Scope != scopeCdb && m.Scope != scopePdb {
+		return fmt.Errorf("%s: unknown scope %q", m.Name, m.Scope)
+	}
+
+	if m.Interval < 0 {
+		return fmt.Errorf("%s: interval must not be negative", m.Name)
+	}
+
+	labels := make([]string, 0, len(m.Labels)+2)
+	hasLabel := make(map[string]bool)
+	for _, l := range m.Labels {
+		l = strings.ToLower(l)
+		if !metricNamePattern.MatchString(l) {
+			return fmt.Errorf("%s: invalid label column %q", m.Name, l)
+		}
+		if l == "con_id" || l == "con_name" {
+			return fmt.Errorf("%s: label %q is reserved for the container of the query", m.Name, l)
+		}
+		if hasLabel[l] {
+			return fmt.Errorf("%s: duplicate label column %q", m.Name, l)
+		}
+		labels = append(labels, l)
+		hasLabel[l] = true
+	}
+	m.Labels = labels
+	// keep series of different containers apart
+	labels = append(labels, "con_id", "con_name")
+
+	m.descs = make(map[string]*prometheus.Desc)
+	for col, help := range m.Values {
+		col = strings.ToLower(col)
+		if !metricNamePattern.MatchString(col) {
+			return fmt.Errorf("%s: invalid value column %q", m.Name, col)
+		}
+		if hasLabel[col] {
+			return fmt.Errorf("%s: column %q is both label and value", m.Name, col)
+		}
+		if help == "" {
+			help = m.Help
+		}
+		if help == "" {
+			help = "Custom metric " + m.Name
+		}
+		m.descs[col] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, m.Name, col),
+			help, labels, nil)
+	}
+	return nil
+}
+
+// ScrapeCustomMetric runs the query of a custom metric.
+type ScrapeCustomMetric struct {
+	Metric *CustomMetric
+}
+
+func (s ScrapeCustomMetric) Name() string {
+	return "custom_" + s.Metric.Name
+}
+
+func (s ScrapeCustomMetric) Help() string {
+	return s.Metric.Help
+}
+
+func (s ScrapeCustomMetric) Version() float64 {
+	return s.Metric.MinVersion
+}
+
+func (s ScrapeCustomMetric) Requirement() Requirement {
+	req := Requirement{Mounted: s.Metric.Mounted}
+	switch s.Metric.Scope {
+	case scopeCdb:
+		req.Container = ContainerRoot
+	case scopePdb:
+		req.Container = ContainerPdb
+	}
+	return req
+}
+
+func (s ScrapeCustomMetric) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	m := s.Metric
+	columns, rows, err := dbcli.FetchRowsWithColumnsContext(ctx, m.Sql)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int)
+	for i, col := range columns {
+		index[col] = i
+	}
+	for _, col := range m.Labels {
+		if _, ok := index[col]; !ok {
+			return fmt.Errorf("%s: label column %q not in query result", m.Name, col)
+		}
+	}
+	for col := range m.descs {
+		if _, ok := index[col]; !ok {
+			return fmt.Errorf("%s: value column %q not in query result", m.Name, col)
+		}
+	}
+
+	out := newMetricSender(ch)
+	for _, r := range dbutil.NamedRows(columns, rows) {
+		row := out.row()
+		var labelValues []string
+		for _, col := range m.Labels {
+			labelValues = append(labelValues, r.String(col))
+		}
+		labelValues = append(labelValues, ora.ConId, ora.ConName)
+
+		values := make(map[string]float64, len(m.descs))
+		for col := range m.descs {
+			values[col] = r.Float(col)
+		}
+		if err := r.Err(); err != nil {
+			out.skip(fmt.Errorf("%s: %s", m.Name, err))
+			continue
+		}
+		for col, desc := range m.descs {
+			row.send(desc, m.valueType, values[col], labelValues...)
+		}
+	}
+	return out.err()
+}
+
+// CustomScrapers returns a scraper for each custom metric of the config.
+func (c *Config) CustomScrapers() []Scraper {
+	var scrapers []Scraper
+	for _, m := range c.CustomMetrics {
+		scrapers = append(scrapers, ScrapeCustomMetric{Metric: m})
+	}
+	return scrapers
+}
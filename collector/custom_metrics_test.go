@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+func TestLoadCustomMetrics(t *testing.T) {
+	file := writeConfig(t, `
+metrics:
+  - name: app_queue
+    help: Application queue
+    sql: select queue_name, depth, oldest_age from app.queues
+    labels: [QUEUE_NAME]
+    values:
+      depth: Messages in the queue
+      oldest_age: ""
+    minVersion: 12.1
+    scope: pdb
+    interval: 5m
+`)
+	metrics, err := loadCustomMetrics(file, yaml.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("got %d metrics, want 1", len(metrics))
+	}
+
+	m := metrics[0]
+	if m.Type != "gauge" || m.Scope != scopePdb || m.Interval != 5*time.Minute || m.Labels[0] != "queue_name" {
+		t.Fatalf("unexpected metric: %+v", m)
+	}
+
+	desc := m.descs["depth"].String()
+	for _, s := range []string{`"oracle_app_queue_depth"`, `"Messages in the queue"`, "queue_name", "con_id", "con_name"} {
+		if !strings.Contains(desc, s) {
+			t.Fatalf("%s not in desc %s", s, desc)
+		}
+	}
+	if !strings.Contains(m.descs["oldest_age"].String(), `"Application queue"`) {
+		t.Fatalf("help of metric not used for value without help: %s", m.descs["oldest_age"])
+	}
+}
+
+func TestLoadCustomMetricsInvalid(t *testing.T) {
+	cases := map[string]string{
+		"no sql": `
+metrics:
+  - name: app_queue
+    values: {depth: ""}`,
+		"no values": `
+metrics:
+  - name: app_queue
+    sql: select 1 from dual`,
+		"bad type": `
+metrics:
+  - name: app_queue
+    sql: select 1 as depth from dual
+    values: {depth: ""}
+    type: summary`,
+		"label is value": `
+metrics:
+  - name: app_queue
+    sql: select 1 as depth from dual
+    labels: [depth]
+    values: {depth: ""}`,
+		"reserved label": `
+metrics:
+  - name: app_queue
+    sql: select con_id, 1 as depth from v$containers
+    labels: [CON_ID]
+    values: {depth: ""}`,
+		"exporter metric": `
+metrics:
+  - name: exporter
+    sql: select 1 as scrapes_total from dual
+    values: {scrapes_total: ""}`,
+		"up metric": `
+metrics:
+  - name: up
+    sql: select 1 as x from dual
+    values: {x: ""}`,
+		"builtin subsystem": `
+metrics:
+  - name: stat
+    sql: select 1 as user_commits from dual
+    values: {user_commits: ""}`,
+		"duplicate metric": `
+metrics:
+  - name: app
+    sql: select 1 as queue_depth from dual
+    values: {queue_depth: ""}
+  - name: app_queue
+    sql: select 1 as depth from dual
+    values: {depth: ""}`,
+	}
+
+	for name, content := range cases {
+		_, err := loadCustomMetrics(writeConfig(t, content), yaml.Unmarshal)
+		if err == nil {
+			t.Fatalf("%s: invalid custom metric not rejected", name)
+		}
+	}
+}
+
+func TestScrapeCustomMetric(t *testing.T) {
+	m := &CustomMetric{
+		Name:   "app_queue",
+		Sql:    "select queue_name, depth from app.queues",
+		Labels: []string{"queue_name"},
+		Values: map[string]string{"depth": "Messages in the queue"},
+	}
+	if err := m.init(); err != nil {
+		t.Fatal(err)
+	}
+	ora := &InstanceInfoAll{PdbInfo: PdbInfo{ConName: "PDB1", ConId: "3"}, PdbFlag: true}
+	q := dbutil.NewFakeQuerier().
+		Add(`from app\.queues`, []string{"queue_name", "depth"},
+			dbutil.Row{"orders", 12.0},
+			dbutil.Row{nil, 0.0})
+
+	testScrape(t, ScrapeCustomMetric{Metric: m}, q, ora, `
+# HELP oracle_app_queue_depth Messages in the queue
+# TYPE oracle_app_queue_depth gauge
+oracle_app_queue_depth{con_id="3",con_name="PDB1",queue_name=""} 0
+oracle_app_queue_depth{con_id="3",con_name="PDB1",queue_name="orders"} 12
+`)
+}
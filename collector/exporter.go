@@ -2,11 +2,17 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strings"
+
 	// "database/sql"
 
 	// "time"
 
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
@@ -26,25 +32,75 @@ var (
 		prometheus.BuildFQName(namespace, exporter, "db_connect_status"),
 		"Database Connect Status",
 		[]string{"message"}, nil)
+
+	collectorSkippedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "collector_skipped"),
+		"Collector skipped because it does not apply to the database, by reason: version, edition, container, database_role, open_mode or rac.",
+		[]string{"collector", "con_name", "reason"}, nil)
+
+	poolLabels = []string{"service"}
+
+	poolConnectedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "pool_connected"),
+		"Whether the connection pool of the service is connected (1) or not (0).",
+		poolLabels, nil)
+	poolOpenConnectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "pool_open_connections"),
+		"Number of established connections of the pool, both in use and idle.",
+		poolLabels, nil)
+	poolInUseConnectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "pool_in_use_connections"),
+		"Number of connections of the pool currently in use.",
+		poolLabels, nil)
+	poolIdleConnectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "pool_idle_connections"),
+		"Number of idle connections of the pool.",
+		poolLabels, nil)
+	poolWaitCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "pool_wait_count_total"),
+		"Total number of connections waited for.",
+		poolLabels, nil)
+	poolWaitDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "pool_wait_duration_seconds_total"),
+		"Total time blocked waiting for a new connection.",
+		poolLabels, nil)
+	poolReconnectsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "pool_reconnects_total"),
+		"Total number of times a broken connection pool was reopened.",
+		poolLabels, nil)
+	poolFailuresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "pool_connect_failures"),
+		"Number of consecutive failed connect attempts of the pool.",
+		poolLabels, nil)
+)
+
+// collector label values of errors which do not come from a scraper
+const (
+	connectionCollector   = "connection"
+	instanceInfoCollector = "instance_info"
 )
 
 type Exporter struct {
 	ctx      context.Context
 	scrapers []Scraper
+	cfg      *Config
+	target   *Target
 	dbclient *dbutil.OracleClient
 	metrics  Metrics
+	// errors counts the errors of the current scrape
+	errors int32
+	// up is whether the current scrape connected to the database
+	up bool
 }
 
-func New(ctx context.Context, scrapers []Scraper, configFile string) *Exporter {
-	metrics := NewMetrics()
-
-	dbclient := dbutil.NewOracleClient(configFile)
-
+func New(ctx context.Context, scrapers []Scraper, target *Target, cfg *Config) *Exporter {
 	exporter := Exporter{
 		ctx:      ctx,
 		scrapers: scrapers,
-		metrics:  metrics,
-		dbclient: dbclient}
+		cfg:      cfg,
+		target:   target,
+		metrics:  target.Metrics,
+		dbclient: target.Client}
 
 	return &exporter
 }
@@ -53,18 +109,82 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.metrics.Error.Desc()
 	ch <- e.metrics.TotalScrapes.Desc()
 	e.metrics.ScrapeErrors.Describe(ch)
+	e.metrics.BadRows.Describe(ch)
 	ch <- e.metrics.OracleUp.Desc()
-
+	e.metrics.ScrapeDuration.Describe(ch)
+	ch <- scrapeStalenessDesc
 }
 
+// Collect serves the metrics of a collection of the target, shared with the concurrent scrapes of the same
+// collectors. The collection is limited by the scrape timeout of the target, not by the context of the request
+// which started it. If too many collections of the target are in flight, or the request times out before the
+// collection is done, the last good metrics are served instead.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	metrics, age, err := e.target.flights.do(e.ctx, flightKey(e.scrapers), e.dbclient.C.MaxScrapesInFlight, func() ([]prometheus.Metric, bool) {
+		ctx, cancel := context.WithTimeout(context.Background(), e.dbclient.C.GetScrapeTimeout())
+		defer cancel()
+		metrics, _ := collectMetrics(func(ch chan<- prometheus.Metric) error {
+			e.collect(ctx, ch)
+			return nil
+		})
+		return metrics, e.up
+	})
+	if err != nil {
+		log.WithFields(log.Fields{"target": e.target.Name, "error": err}).Error("No Result To Serve")
+		ch <- prometheus.NewInvalidMetric(scrapeStalenessDesc, err)
+		return
+	}
+	if age > 0 {
+		log.WithFields(log.Fields{"target": e.target.Name, "age": age}).Warn("Serve Last Result")
+	}
+	for _, m := range metrics {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeStalenessDesc, prometheus.GaugeValue, age.Seconds())
+}
+
+func (e *Exporter) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	e.metrics.TotalScrapes.Inc()
+
 	// scrape each cdb
-	e.scrape(e.ctx, ch)
+	e.scrape(ctx, ch)
+
+	if atomic.LoadInt32(&e.errors) > 0 {
+		e.metrics.Error.Set(1)
+	} else {
+		e.metrics.Error.Set(0)
+	}
 
 	ch <- e.metrics.Error
 	ch <- e.metrics.OracleUp
 	ch <- e.metrics.TotalScrapes
 	e.metrics.ScrapeErrors.Collect(ch)
+	e.metrics.BadRows.Collect(ch)
+	e.metrics.ScrapeDuration.Collect(ch)
+
+	e.collectPoolStats(ch)
+
+	err := dbutil.SaveRecording()
+	if err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Save Recorded Queries Failed")
+	}
+}
+
+func (e *Exporter) collectPoolStats(ch chan<- prometheus.Metric) {
+	for _, s := range e.dbclient.Stats() {
+		connected := 0.0
+		if s.Connected {
+			connected = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(poolConnectedDesc, prometheus.GaugeValue, connected, s.Service)
+		ch <- prometheus.MustNewConstMetric(poolOpenConnectionsDesc, prometheus.GaugeValue, float64(s.OpenConnections), s.Service)
+		ch <- prometheus.MustNewConstMetric(poolInUseConnectionsDesc, prometheus.GaugeValue, float64(s.InUse), s.Service)
+		ch <- prometheus.MustNewConstMetric(poolIdleConnectionsDesc, prometheus.GaugeValue, float64(s.Idle), s.Service)
+		ch <- prometheus.MustNewConstMetric(poolWaitCountDesc, prometheus.CounterValue, float64(s.WaitCount), s.Service)
+		ch <- prometheus.MustNewConstMetric(poolWaitDurationDesc, prometheus.CounterValue, s.WaitDuration.Seconds(), s.Service)
+		ch <- prometheus.MustNewConstMetric(poolReconnectsDesc, prometheus.CounterValue, float64(s.Reconnects), s.Service)
+		ch <- prometheus.MustNewConstMetric(poolFailuresDesc, prometheus.GaugeValue, float64(s.Failures), s.Service)
+	}
 }
 
 // case 1: version < 12c
@@ -75,14 +195,18 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
 	// var err error
 
-	err := e.dbclient.Init()
+	err := e.dbclient.Init(ctx)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("Can not Init DB Connection")
-		e.metrics.Error.Set(1)
+		e.recordError(connectionCollector, "", err)
 		e.metrics.OracleUp.Set(0)
-		ch <- prometheus.MustNewConstMetric(dbConnectStatusDesc, prometheus.GaugeValue, 1, fmt.Sprintf("%s", err))
+		e.up = false
+		// localized oracle messages may not be UTF-8
+		ch <- prometheus.MustNewConstMetric(dbConnectStatusDesc, prometheus.GaugeValue, 1, strings.ToValidUTF8(fmt.Sprintf("%s", err), "?"))
 		return
 	}
+	e.metrics.OracleUp.Set(1)
+	e.up = true
 
 	log.WithFields(log.Fields{"dbconfig": e.dbclient.C}).Debug("DB CONFIG")
 
@@ -91,8 +215,10 @@ func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
 	oracleInfo, err := getOracleInfoAll(ctx, e.dbclient)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("Get Oracle Info has error")
+		e.recordError(instanceInfoCollector, "", err)
 		return
 	}
+	oracleInfo.Rac = oracleInfo.ClusterDatabase && !e.dbclient.C.DisableRac
 	log.WithFields(log.Fields{"version": oracleInfo.Version,
 		"dbid":         oracleInfo.Dbid,
 		"instanceName": oracleInfo.InstanceName,
@@ -100,68 +226,169 @@ func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
 		"pdb":          oracleInfo.ConName,
 		"databaseRole": oracleInfo.DatabaseRole}).Info("Scrape Oracle")
 
-	e.scrapeOne(ctx, ch, oracleInfo)
+	e.scrapeOne(ctx, e.dbclient, ch, oracleInfo)
 
-	if oracleInfo.VersionNum >= 12 && oracleInfo.ConId == "1" {
+	// the PDBs of a mounted CDB are not open and can not be connected to
+	if oracleInfo.VersionNum >= 12 && oracleInfo.ConId == "1" && oracleInfo.OpenMode != openModeMounted {
 		e.scrapePdbs(ctx, ch)
 	}
-
-	err = e.dbclient.CloseConnection()
-	if err != nil {
-		log.WithFields(log.Fields{"error": err}).Error("Close Database Connection has error")
-	}
 }
 
 func (e *Exporter) scrapePdbs(ctx context.Context, ch chan<- prometheus.Metric) {
-	for _, pdb := range e.dbclient.C.Pdbs {
-		err := e.dbclient.ReInitWithPdb(pdb)
-		if err != nil {
-			log.WithFields(log.Fields{"pdb": pdb, "error": err}).Error("Init With Pdb Failed")
-			// try next pdb
-			continue
-		}
+	pdbs := e.pdbsToScrape(ctx, e.dbclient, ch)
 
-		oracleInfo, err := getOracleInfoAll(ctx, e.dbclient)
-		if err != nil {
-			log.WithFields(log.Fields{"error": err}).Error("Get Oracle Info has error")
-			return
-		}
-		log.WithFields(log.Fields{"version": oracleInfo.Version,
-			"dbid":         oracleInfo.Dbid,
-			"instanceName": oracleInfo.InstanceName,
-			"dbUniqueName": oracleInfo.DbUniqueName,
-			"pdb":          oracleInfo.ConName,
-			"databaseRole": oracleInfo.DatabaseRole}).Info("Scrape Oracle")
+	e.eachPdb(pdbs, func(pdb string) {
+		e.scrapePdb(ctx, ch, pdb)
+	})
+}
 
-		oracleInfo.PdbFlag = true
-		e.scrapeOne(ctx, ch, oracleInfo)
+// eachPdb runs scrape for each of the pdbs, each pdb has its own connection pool,
+// scrape up to pdbConcurrency of them at a time.
+func (e *Exporter) eachPdb(pdbs []string, scrape func(pdb string)) {
+	sem := make(chan struct{}, e.dbclient.C.GetPdbConcurrency())
+	var wg sync.WaitGroup
+	for _, pdb := range pdbs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(pdb string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			scrape(pdb)
+		}(pdb)
+	}
+	wg.Wait()
+}
 
+// pdbsToScrape returns the PDBs discovered with dbcli if the discovery is enabled,
+// the configured pdbs otherwise or if the discovery fails.
+func (e *Exporter) pdbsToScrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric) []string {
+	if !e.dbclient.C.PdbDiscovery.Enabled {
+		return e.dbclient.C.Pdbs
+	}
+	discovered, err := discoverPdbs(ctx, dbcli, e.dbclient.C.PdbDiscovery, ch)
+	if e.recordBadRows(pdbDiscoveryCollector, "", err) {
+		err = nil
 	}
+	if err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Discover PDBs has error, use configured pdbs")
+		e.recordError(pdbDiscoveryCollector, "", err)
+		return e.dbclient.C.Pdbs
+	}
+	// release the connections of dropped or closed PDBs
+	e.dbclient.RetainPdbs(discovered)
+	return discovered
+}
+
+func (e *Exporter) scrapePdb(ctx context.Context, ch chan<- prometheus.Metric, pdb string) {
+	pdbclient := e.dbclient.WithPdb(pdb)
+	err := pdbclient.Init(ctx)
+	if err != nil {
+		log.WithFields(log.Fields{"pdb": pdb, "error": err}).Error("Init With Pdb Failed")
+		e.recordError(connectionCollector, pdb, err)
+		return
+	}
+
+	oracleInfo, err := getOracleInfoAll(ctx, pdbclient)
+	if err != nil {
+		log.WithFields(log.Fields{"pdb": pdb, "error": err}).Error("Get Oracle Info has error")
+		e.recordError(instanceInfoCollector, pdb, err)
+		return
+	}
+	log.WithFields(log.Fields{"version": oracleInfo.Version,
+		"dbid":         oracleInfo.Dbid,
+		"instanceName": oracleInfo.InstanceName,
+		"dbUniqueName": oracleInfo.DbUniqueName,
+		"pdb":          oracleInfo.ConName,
+		"databaseRole": oracleInfo.DatabaseRole}).Info("Scrape Oracle")
 
+	oracleInfo.Rac = oracleInfo.ClusterDatabase && !e.dbclient.C.DisableRac
+	e.scrapeOne(ctx, pdbclient, ch, oracleInfo)
 }
 
-func (e *Exporter) scrapeOne(ctx context.Context, ch chan<- prometheus.Metric, oracleInfo *InstanceInfoAll) {
+// scrapeOne runs the collectors of a container in the order of their priority, up to collectorConcurrency
+// of them at a time as each needs a connection of the container, by default one after another. A collector
+// starts when one of the higher priority ones is done. When the scrape timeout runs out, the metrics scraped
+// so far are served and the remaining collectors fail as timeout, unless their metrics are cached.
+func (e *Exporter) scrapeOne(ctx context.Context, dbclient *dbutil.OracleClient, ch chan<- prometheus.Metric, oracleInfo *InstanceInfoAll) {
+	sem := make(chan struct{}, e.dbclient.C.GetCollectorConcurrency())
 	var wg sync.WaitGroup
-	defer wg.Wait()
-	for _, scraper := range e.scrapers {
-		// if version < scraper.Version() {
-		// 	continue
-		// }
-
+	for _, scraper := range e.sortedScrapers() {
+		if reason := skipReason(scraper, oracleInfo); reason != "" {
+			log.WithFields(log.Fields{"collector": scraper.Name(), "pdb": oracleInfo.ConName, "reason": reason}).Debug("skip collector")
+			ch <- prometheus.MustNewConstMetric(collectorSkippedDesc, prometheus.GaugeValue, 1, scraper.Name(), oracleInfo.ConName, reason)
+			continue
+		}
 		wg.Add(1)
+		sem <- struct{}{}
 		go func(scraper Scraper) {
 			defer wg.Done()
-			scraper.Scrape(ctx, e.dbclient, ch, oracleInfo)
+			defer func() { <-sem }()
+			e.scrapeCollector(ctx, scraper, dbclient, ch, oracleInfo)
 		}(scraper)
 	}
+	wg.Wait()
+}
+
+// scrapeCollector runs the scraper, its query is cancelled after the timeout of the collector.
+func (e *Exporter) scrapeCollector(ctx context.Context, scraper Scraper, dbclient *dbutil.OracleClient, ch chan<- prometheus.Metric, oracleInfo *InstanceInfoAll) {
+	if timeout := e.cfg.ScrapeTimeout(scraper.Name()); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	interval := e.cfg.ScrapeInterval(scraper.Name())
+	err := e.target.cache.scrape(ctx, withState(scraper, e.target.state), interval, dbclient, ch, oracleInfo)
+	e.metrics.ScrapeDuration.WithLabelValues(scraper.Name(), oracleInfo.ConName).Set(time.Since(start).Seconds())
+	if e.recordBadRows(scraper.Name(), oracleInfo.ConName, err) {
+		return
+	}
+	if err != nil {
+		log.WithFields(log.Fields{"collector": scraper.Name(), "pdb": oracleInfo.ConName, "error": err}).Error("Scrape has error")
+		e.recordError(scraper.Name(), oracleInfo.ConName, err)
+	}
+}
+
+// sortedScrapers returns the scrapers by priority, and by name within a priority.
+func (e *Exporter) sortedScrapers() []Scraper {
+	scrapers := append([]Scraper{}, e.scrapers...)
+	sort.SliceStable(scrapers, func(i, j int) bool {
+		pi, pj := e.cfg.ScrapePriority(scrapers[i].Name()), e.cfg.ScrapePriority(scrapers[j].Name())
+		if pi != pj {
+			return pi < pj
+		}
+		return scrapers[i].Name() < scrapers[j].Name()
+	})
+	return scrapers
+}
+
+// recordError counts a failed collector of a container in the current scrape and in scrape_errors_total.
+func (e *Exporter) recordError(collector string, conName string, err error) {
+	atomic.AddInt32(&e.errors, 1)
+	e.metrics.ScrapeErrors.WithLabelValues(collector, conName, errorCode(err)).Inc()
+}
+
+// recordBadRows counts the rows skipped by a collector if err is a *BadRowsError, the other metrics
+// of the collector were sent, so it is not a scrape error.
+func (e *Exporter) recordBadRows(collector string, conName string, err error) bool {
+	var bad *BadRowsError
+	if !errors.As(err, &bad) {
+		return false
+	}
+	log.WithFields(log.Fields{"collector": collector, "pdb": conName, "rows": bad.Rows, "error": bad.Err}).Warn("Skip Bad Rows")
+	e.metrics.BadRows.WithLabelValues(collector, conName).Add(float64(bad.Rows))
+	return true
 }
 
 // Metrics represents exporter metrics which values can be carried between http requests.
 type Metrics struct {
-	TotalScrapes prometheus.Counter
-	ScrapeErrors *prometheus.CounterVec
-	Error        prometheus.Gauge
-	OracleUp     prometheus.Gauge
+	TotalScrapes   prometheus.Counter
+	ScrapeErrors   *prometheus.CounterVec
+	BadRows        *prometheus.CounterVec
+	Error          prometheus.Gauge
+	OracleUp       prometheus.Gauge
+	ScrapeDuration *prometheus.GaugeVec
 }
 
 // NewMetrics creates new Metrics instance.
@@ -179,7 +406,13 @@ func NewMetrics() Metrics {
 			Subsystem: subsystem,
 			Name:      "scrape_errors_total",
 			Help:      "Total number of times an error occurred scraping a Oracle.",
-		}, []string{"collector"}),
+		}, []string{"collector", "con_name", "code"}),
+		BadRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "bad_rows_total",
+			Help:      "Total number of query result rows skipped by a collector because of unexpected NULLs or types, or invalid label values.",
+		}, []string{"collector", "con_name"}),
 		Error: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: subsystem,
@@ -191,5 +424,11 @@ func NewMetrics() Metrics {
 			Name:      "up",
 			Help:      "Whether the Oracle server is up.",
 		}),
+		ScrapeDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "scrape_duration_seconds",
+			Help:      "Duration of the last scrape of a collector.",
+		}, []string{"collector", "con_name"}),
 	}
 }
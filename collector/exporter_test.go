@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// orderScraper records the order of the scrapes, a blocking one runs until its context is done
+// or wait is closed, done is closed after the scrape if set.
+type orderScraper struct {
+	name  string
+	block bool
+	wait  chan struct{}
+	done  chan struct{}
+	mu    *sync.Mutex
+	order *[]string
+}
+
+func (s orderScraper) Name() string     { return s.name }
+func (s orderScraper) Help() string     { return "test scraper" }
+func (s orderScraper) Version() float64 { return 10.2 }
+
+func (s orderScraper) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	s.mu.Lock()
+	*s.order = append(*s.order, s.name)
+	s.mu.Unlock()
+	if s.done != nil {
+		defer close(s.done)
+	}
+	if s.block {
+		select {
+		case <-ctx.Done():
+		case <-s.wait:
+		}
+	}
+	return ctx.Err()
+}
+
+func TestScrapeOrderAndTimeout(t *testing.T) {
+	timeout := 50 * time.Millisecond
+	priority := 1
+	cfg := &Config{Collectors: map[string]CollectorConfig{
+		"test_slow": {Timeout: &timeout, Priority: &priority},
+	}}
+
+	var mu sync.Mutex
+	var order []string
+	scraper := func(name string, block bool) Scraper {
+		return orderScraper{name: name, block: block, mu: &mu, order: &order}
+	}
+	target := &Target{Client: dbutil.NewOracleClient(dbutil.OracleConfig{}), Metrics: NewMetrics(), cache: newScrapeCache()}
+	e := New(context.Background(), []Scraper{
+		scraper("test_other", false),
+		scraper("oracle_tablespace", false),
+		scraper("test_slow", true),
+		scraper("oracle_wait_event", false),
+	}, target, cfg)
+
+	start := time.Now()
+	collectMetrics(func(ch chan<- prometheus.Metric) error {
+		e.scrapeOne(e.ctx, nil, ch, &InstanceInfoAll{InstanceInfo: InstanceInfo{VersionNum: 19.0}})
+		return nil
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("slow collector not cancelled after its timeout, took %s", elapsed)
+	}
+
+	want := []string{"test_slow", "oracle_wait_event", "test_other", "oracle_tablespace"}
+	if len(order) != len(want) {
+		t.Fatalf("scraped %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("scraped %v, want %v", order, want)
+		}
+	}
+
+	if got := testutil.ToFloat64(target.Metrics.ScrapeErrors.WithLabelValues("test_slow", "", "timeout")); got != 1 {
+		t.Errorf("timeout errors of test_slow %v, want 1", got)
+	}
+	if e.errors != 1 {
+		t.Errorf("errors %d, want 1", e.errors)
+	}
+}
+
+func TestScrapeCollectorConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	// the slow collector runs until the last one is done, which needs a second connection
+	release := make(chan struct{})
+	slow := orderScraper{name: "test_slow", block: true, wait: release, mu: &mu, order: &order}
+	last := orderScraper{name: "oracle_tablespace", done: release, mu: &mu, order: &order}
+	target := &Target{
+		Client:  dbutil.NewOracleClient(dbutil.OracleConfig{CollectorConcurrency: 2}),
+		Metrics: NewMetrics(),
+		cache:   newScrapeCache(),
+	}
+	e := New(context.Background(), []Scraper{last, slow}, target, &Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	collectMetrics(func(ch chan<- prometheus.Metric) error {
+		e.scrapeOne(ctx, nil, ch, &InstanceInfoAll{InstanceInfo: InstanceInfo{VersionNum: 19.0}})
+		return nil
+	})
+	if e.errors != 0 {
+		t.Fatalf("errors %d, collectors not run in parallel", e.errors)
+	}
+	if len(order) != 2 {
+		t.Fatalf("scraped %v", order)
+	}
+}
+
+func TestScrapePdbConcurrency(t *testing.T) {
+	target := &Target{
+		Client:  dbutil.NewOracleClient(dbutil.OracleConfig{PdbConcurrency: 2}),
+		Metrics: NewMetrics(),
+		cache:   newScrapeCache(),
+	}
+	e := New(context.Background(), nil, target, &Config{})
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	scraped := map[string]bool{}
+	pdbs := []string{"PDB1", "PDB2", "PDB3", "PDB4", "PDB5"}
+	e.eachPdb(pdbs, func(pdb string) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		scraped[pdb] = true
+		mu.Unlock()
+		if pdb == "PDB1" {
+			e.recordError(connectionCollector, pdb, context.DeadlineExceeded)
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+
+	if maxRunning > 2 {
+		t.Fatalf("%d PDBs scraped at a time, want at most 2", maxRunning)
+	}
+	if maxRunning < 2 {
+		t.Errorf("PDBs not scraped in parallel")
+	}
+	// the error of a PDB does not stop the scrapes of the others
+	if len(scraped) != len(pdbs) {
+		t.Errorf("scraped %v, want %v", scraped, pdbs)
+	}
+	if e.errors != 1 {
+		t.Errorf("errors %d, want 1", e.errors)
+	}
+}
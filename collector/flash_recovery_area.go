@@ -28,10 +28,11 @@ func (ScrapeOracleRecoveryAreaStat) Version() float64 {
 	return 10.2
 }
 
-func (ScrapeOracleRecoveryAreaStat) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	if ora.PdbFlag {
-		return nil
-	}
+func (ScrapeOracleRecoveryAreaStat) Requirement() Requirement {
+	return Requirement{Container: ContainerRoot, Mounted: true}
+}
+
+func (ScrapeOracleRecoveryAreaStat) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 	sql := `select
     substr(name,1,64) as name,
     space_limit as space_limit,
@@ -40,32 +41,42 @@ func (ScrapeOracleRecoveryAreaStat) Scrape(ctx context.Context, dbcli *dbutil.Or
     number_of_files
 from V$RECOVERY_FILE_DEST where space_limit > 0`
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return err
 	}
+	out := newMetricSender(ch)
 	for _, r := range rows {
-		recoveryArea := r[0].(string)
+		row := out.row()
+		recoveryArea := r.String("name")
+		spaceLimit := r.Float("space_limit")
+		spaceUsed := r.Float("space_used")
+		spaceReclaimable := r.Float("space_reclaimable")
+		numberOfFiles := r.Float("number_of_files")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 
-		ch <- prometheus.MustNewConstMetric(
-			oracleRecoveryAreaDesc, prometheus.GaugeValue, r[1].(float64),
+		row.send(
+			oracleRecoveryAreaDesc, prometheus.GaugeValue, spaceLimit,
 			recoveryArea, "total")
 
-		ch <- prometheus.MustNewConstMetric(
-			oracleRecoveryAreaDesc, prometheus.GaugeValue, r[2].(float64),
+		row.send(
+			oracleRecoveryAreaDesc, prometheus.GaugeValue, spaceUsed,
 			recoveryArea, "used")
 
-		ch <- prometheus.MustNewConstMetric(
-			oracleRecoveryAreaDesc, prometheus.GaugeValue, r[3].(float64),
+		row.send(
+			oracleRecoveryAreaDesc, prometheus.GaugeValue, spaceReclaimable,
 			recoveryArea, "reclaimable")
 
-		ch <- prometheus.MustNewConstMetric(
-			oracleRecoveryAreaDesc, prometheus.GaugeValue, r[4].(float64),
+		row.send(
+			oracleRecoveryAreaDesc, prometheus.GaugeValue, numberOfFiles,
 			recoveryArea, "number_of_files")
 
-		ch <- prometheus.MustNewConstMetric(
-			oracleRecoveryAreaDesc, prometheus.GaugeValue, r[2].(float64)*100.0/r[1].(float64),
+		row.send(
+			oracleRecoveryAreaDesc, prometheus.GaugeValue, spaceUsed*100.0/spaceLimit,
 			recoveryArea, "used_pct")
 	}
-	return nil
+	return out.err()
 }
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	scrapeStalenessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "scrape_staleness_seconds"),
+		"Age of the served metrics, 0 for a new collection, the time since the last good collection if too many scrapes were in flight or the scrape timed out.",
+		nil, nil)
+
+	errTooManyScrapes = errors.New("too many scrapes in flight and no previous result")
+)
+
+// scrapeFlights deduplicates the concurrent collections of a target with the same collectors,
+// and limits the number of different collections in flight.
+type scrapeFlights struct {
+	mu       sync.Mutex
+	inFlight map[string]*flight
+	// last is the last good result by key
+	last map[string]*flight
+	// running counts the collections in flight, which may outlive the requests waiting for them
+	running sync.WaitGroup
+}
+
+type flight struct {
+	done      chan struct{}
+	metrics   []prometheus.Metric
+	err       error
+	updatedAt time.Time
+}
+
+func newScrapeFlights() *scrapeFlights {
+	return &scrapeFlights{inFlight: make(map[string]*flight), last: make(map[string]*flight)}
+}
+
+// flightKey identifies the collections of the same scrapers.
+func flightKey(scrapers []Scraper) string {
+	names := make([]string, 0, len(scrapers))
+	for _, s := range scrapers {
+		names = append(names, s.Name())
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// do returns the metrics of collect, or of the collection of the key in flight. collect runs in its own
+// goroutine and does not depend on ctx, which only limits the time the caller waits for it. If max collections
+// are in flight, or ctx is done before the collection, the last good metrics of the key are returned with
+// their age. collect returns whether its metrics are good, to be served later.
+// An error is returned if there is no good result to serve, or if collect panicked.
+func (f *scrapeFlights) do(ctx context.Context, key string, max int, collect func() ([]prometheus.Metric, bool)) (metrics []prometheus.Metric, age time.Duration, err error) {
+	f.mu.Lock()
+	fl, found := f.inFlight[key]
+	if !found {
+		if max > 0 && len(f.inFlight) >= max {
+			f.mu.Unlock()
+			return f.lastResult(key, errTooManyScrapes)
+		}
+		fl = &flight{done: make(chan struct{})}
+		f.inFlight[key] = fl
+		f.running.Add(1)
+		go f.run(key, fl, collect)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-fl.done:
+		return fl.metrics, 0, fl.err
+	case <-ctx.Done():
+		return f.lastResult(key, ctx.Err())
+	}
+}
+
+// run collects the metrics of the flight, the flight ends even if collect panics.
+func (f *scrapeFlights) run(key string, fl *flight, collect func() ([]prometheus.Metric, bool)) {
+	good := false
+	defer f.running.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithFields(log.Fields{"collectors": key, "panic": r, "stack": string(debug.Stack())}).Error("Collection Panicked")
+			fl.metrics, good = nil, false
+			fl.err = fmt.Errorf("collection panicked: %v", r)
+		}
+
+		f.mu.Lock()
+		fl.updatedAt = time.Now()
+		delete(f.inFlight, key)
+		if good {
+			f.last[key] = fl
+		}
+		f.mu.Unlock()
+		close(fl.done)
+	}()
+
+	var metrics []prometheus.Metric
+	metrics, good = collect()
+	fl.metrics = snapshotMetrics(metrics)
+}
+
+// wait waits until the collections in flight are done.
+func (f *scrapeFlights) wait() {
+	f.running.Wait()
+}
+
+// lastResult returns the last good metrics of the key with their age, or err if there are none.
+func (f *scrapeFlights) lastResult(key string, err error) ([]prometheus.Metric, time.Duration, error) {
+	f.mu.Lock()
+	last, found := f.last[key]
+	f.mu.Unlock()
+	if !found {
+		return nil, 0, err
+	}
+	return last.metrics, time.Since(last.updatedAt), nil
+}
+
+// snapshotMetrics copies the values of the metrics, so that a served result does not change with the
+// collectors of the self metrics, like up and the error counters, which are updated by later collections.
+func snapshotMetrics(metrics []prometheus.Metric) []prometheus.Metric {
+	snapshots := make([]prometheus.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			// an invalid metric is served as it is, to fail the scrape
+			snapshots = append(snapshots, m)
+			continue
+		}
+		snapshots = append(snapshots, metricSnapshot{desc: m.Desc(), pb: pb})
+	}
+	return snapshots
+}
+
+// metricSnapshot is a metric with the values written at the time of the collection.
+type metricSnapshot struct {
+	desc *prometheus.Desc
+	pb   *dto.Metric
+}
+
+func (m metricSnapshot) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m metricSnapshot) Write(out *dto.Metric) error {
+	out.Label = m.pb.Label
+	out.Gauge = m.pb.Gauge
+	out.Counter = m.pb.Counter
+	out.Summary = m.pb.Summary
+	out.Untyped = m.pb.Untyped
+	out.Histogram = m.pb.Histogram
+	out.TimestampMs = m.pb.TimestampMs
+	return nil
+}
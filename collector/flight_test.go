@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func testMetrics(v float64) []prometheus.Metric {
+	return []prometheus.Metric{prometheus.MustNewConstMetric(testCounterDesc, prometheus.GaugeValue, v)}
+}
+
+// startFlight runs a collection of key which is in flight until release is closed.
+func startFlight(t *testing.T, f *scrapeFlights, key string, release chan struct{}, good bool) *sync.WaitGroup {
+	t.Helper()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f.do(context.Background(), key, 0, func() ([]prometheus.Metric, bool) {
+			<-release
+			return testMetrics(1), good
+		})
+	}()
+	for {
+		f.mu.Lock()
+		_, found := f.inFlight[key]
+		f.mu.Unlock()
+		if found {
+			return &wg
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScrapeFlightsShared(t *testing.T) {
+	f := newScrapeFlights()
+	release := make(chan struct{})
+	leader := startFlight(t, f, "oracle_stat", release, true)
+
+	calls := 0
+	done := make(chan []prometheus.Metric)
+	go func() {
+		metrics, _, _ := f.do(context.Background(), "oracle_stat", 0, func() ([]prometheus.Metric, bool) {
+			calls++
+			return testMetrics(2), true
+		})
+		done <- metrics
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	leader.Wait()
+
+	metrics := <-done
+	if calls != 0 || len(metrics) != 1 {
+		t.Fatalf("concurrent scrape not shared: %d calls, %d metrics", calls, len(metrics))
+	}
+}
+
+func TestScrapeFlightsLimit(t *testing.T) {
+	f := newScrapeFlights()
+	ctx := context.Background()
+	f.do(ctx, "oracle_tablespace", 1, func() ([]prometheus.Metric, bool) { return testMetrics(1), true })
+	f.do(ctx, "oracle_stat", 1, func() ([]prometheus.Metric, bool) { return testMetrics(1), false })
+
+	release := make(chan struct{})
+	inFlight := startFlight(t, f, "oracle_wait_event", release, true)
+	defer func() {
+		close(release)
+		inFlight.Wait()
+	}()
+
+	time.Sleep(time.Millisecond)
+	collect := func() ([]prometheus.Metric, bool) {
+		t.Fatal("collected over the limit")
+		return nil, false
+	}
+	metrics, age, err := f.do(ctx, "oracle_tablespace", 1, collect)
+	if err != nil || len(metrics) != 1 || age <= 0 {
+		t.Fatalf("last good result not served: error %v, %d metrics, age %s", err, len(metrics), age)
+	}
+	// the result of the last scrape of oracle_stat was not good
+	if _, _, err := f.do(ctx, "oracle_stat", 1, collect); err != errTooManyScrapes {
+		t.Fatalf("served a result which was not good, error %v", err)
+	}
+	if _, _, err := f.do(ctx, "oracle_stat", 2, func() ([]prometheus.Metric, bool) { return nil, true }); err != nil {
+		t.Fatalf("limit hit below max: %s", err)
+	}
+}
+
+// TestScrapeFlightsCallerCanceled checks that a caller giving up does not end the collection it started.
+func TestScrapeFlightsCallerCanceled(t *testing.T) {
+	f := newScrapeFlights()
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	first := make(chan error)
+	go func() {
+		_, _, err := f.do(ctx, "oracle_stat", 0, func() ([]prometheus.Metric, bool) {
+			close(started)
+			<-release
+			return testMetrics(1), true
+		})
+		first <- err
+	}()
+	<-started
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("canceled caller without previous result: error %v", err)
+	}
+
+	// the collection goes on and is served to the next caller in full
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		metrics, age, err := f.do(context.Background(), "oracle_stat", 0, func() ([]prometheus.Metric, bool) {
+			t.Error("collection not shared after the first caller gave up")
+			return nil, false
+		})
+		if err != nil || len(metrics) != 1 || age != 0 {
+			t.Errorf("shared result: error %v, %d metrics, age %s", err, len(metrics), age)
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-done
+
+	// a caller giving up later is served the last good result
+	release = make(chan struct{})
+	defer close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	metrics, age, err := f.do(ctx, "oracle_stat", 0, func() ([]prometheus.Metric, bool) {
+		<-release
+		return testMetrics(2), true
+	})
+	if err != nil || len(metrics) != 1 || age <= 0 {
+		t.Fatalf("last good result not served on timeout: error %v, %d metrics, age %s", err, len(metrics), age)
+	}
+}
+
+func TestScrapeFlightsPanic(t *testing.T) {
+	f := newScrapeFlights()
+	_, _, err := f.do(context.Background(), "oracle_stat", 0, func() ([]prometheus.Metric, bool) {
+		panic("scraper bug")
+	})
+	if err == nil {
+		t.Fatal("panic not reported")
+	}
+
+	// the flight ended, the next scrape collects again
+	metrics, _, err := f.do(context.Background(), "oracle_stat", 0, func() ([]prometheus.Metric, bool) {
+		return testMetrics(1), true
+	})
+	if err != nil || len(metrics) != 1 {
+		t.Fatalf("scrape after panic: error %v, %d metrics", err, len(metrics))
+	}
+}
+
+// TestScrapeFlightsSnapshot checks that a served result keeps the values of the self metrics at its collection.
+func TestScrapeFlightsSnapshot(t *testing.T) {
+	f := newScrapeFlights()
+	up := prometheus.NewGauge(prometheus.GaugeOpts{Name: "oracle_up"})
+	up.Set(1)
+	f.do(context.Background(), "oracle_stat", 0, func() ([]prometheus.Metric, bool) {
+		return []prometheus.Metric{up}, true
+	})
+	up.Set(0)
+
+	metrics, _, err := f.lastResult("oracle_stat", nil)
+	if err != nil || len(metrics) != 1 {
+		t.Fatalf("last result: error %v, %d metrics", err, len(metrics))
+	}
+	pb := &dto.Metric{}
+	if err := metrics[0].Write(pb); err != nil {
+		t.Fatal(err)
+	}
+	if pb.GetGauge().GetValue() != 1 {
+		t.Fatalf("served up %v, want the collected 1", pb.GetGauge().GetValue())
+	}
+}
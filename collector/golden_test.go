@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// the collectors enabled by default, oracle_sql_snapshot is disabled by default
+var goldenScrapers = []Scraper{
+	ScrapeOracleStat{},
+	ScrapeOracleWaitEvent{},
+	ScrapeOracleTimeModel{},
+	ScrapeOracleInfo{},
+	ScrapeMemoryInfo{},
+	ScrapeOracleParameter{},
+	ScrapeOracleOsStat{},
+	ScrapeOracleTablespaceStat{},
+	ScrapeOracleRecoveryAreaStat{},
+	ScrapeBlockSessionStat{},
+	ScrapeOracleBackupInfo{},
+	ScrapeOracleAsmStat{},
+	ScrapeActiveTransactionStat{},
+	ScrapeOracleRac{},
+}
+
+// TestGoldenMetrics scrapes each database of testdata by replaying the query results recorded in
+// <name>.json, with the config <name>.yaml, and compares the metrics with <name>.golden.
+// Run go test -run TestGoldenMetrics -update to write the golden files after changing a collector.
+func TestGoldenMetrics(t *testing.T) {
+	for _, name := range []string{"11g_noncdb", "19c_cdb", "19c_pdb"} {
+		t.Run(name, func(t *testing.T) {
+			got := goldenScrape(t, filepath.Join("testdata", name))
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("metrics differ from %s, run with -update and review the diff\ngot:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// goldenScrape collects the metrics of the exporter like the telemetry handler does,
+// and returns them in the text format without the scrape durations, which vary.
+func goldenScrape(t *testing.T, base string) []byte {
+	t.Helper()
+	cfg, err := LoadConfig(base + ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := dbutil.LoadFixtures(base + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbutil.Replay(f)
+	defer dbutil.Replay(nil)
+
+	target := NewTarget("", cfg.OracleConfig)
+	defer target.Close()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(New(context.Background(), goldenScrapers, target, cfg))
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, mf := range mfs {
+		if mf.GetName() == "oracle_exporter_scrape_duration_seconds" {
+			continue
+		}
+		if err := enc.Encode(mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
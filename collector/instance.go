@@ -42,12 +42,14 @@ func (ScrapeOracleInfo) Version() float64 {
 	return 10.2
 }
 
-func (ScrapeOracleInfo) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	if ora.PdbFlag {
-		return nil
-	}
+func (ScrapeOracleInfo) Requirement() Requirement {
+	return Requirement{Container: ContainerRoot, Mounted: true}
+}
+
+func (ScrapeOracleInfo) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 
-	ch <- prometheus.MustNewConstMetric(
+	out := newMetricSender(ch)
+	out.send(
 		oracleInfoDesc, prometheus.GaugeValue,
 		ora.Uptime,
 		ora.InstanceNumber,
@@ -73,5 +75,5 @@ func (ScrapeOracleInfo) Scrape(ctx context.Context, dbcli *dbutil.OracleClient,
 		ora.ConName,
 	)
 
-	return nil
+	return out.err()
 }
@@ -3,6 +3,7 @@ package collector
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"yunche.pro/dtsre/oracledb_exporter/dbutil"
@@ -34,6 +35,10 @@ type InstanceInfo struct {
 	InstanceRole   string
 	DatabaseStatus string
 	VersionNum     float64
+	// Edition is EE, SE, XE or PE
+	Edition string
+	// ClusterDatabase is set for a RAC
+	ClusterDatabase bool
 }
 
 type PdbInfo struct {
@@ -45,10 +50,15 @@ type InstanceInfoAll struct {
 	InstanceInfo
 	DbInfo
 	PdbInfo
+	// PdbFlag is set in a PDB, the session of a pdbs target or a target connected to the service of a PDB
 	PdbFlag bool
+	// Rac is the RAC mode of a cluster database, the instance level collectors query the gv$ views
+	Rac bool
+	// Instances are the names of the instances of a cluster database by inst_id
+	Instances map[string]string
 }
 
-func getOracleInfoAll(ctx context.Context, dbcli *dbutil.OracleClient) (*InstanceInfoAll, error) {
+func getOracleInfoAll(ctx context.Context, dbcli dbutil.Querier) (*InstanceInfoAll, error) {
 	instanceInfo, err := getInstanceInfo(ctx, dbcli)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("Get Oracle Instance Info Error")
@@ -70,12 +80,22 @@ func getOracleInfoAll(ctx context.Context, dbcli *dbutil.OracleClient) (*Instanc
 			return nil, err
 		}
 	}
-	instanceInfoAll := InstanceInfoAll{*instanceInfo, *dbInfo, *pdbInfo, false}
+	instanceInfoAll := InstanceInfoAll{InstanceInfo: *instanceInfo, DbInfo: *dbInfo, PdbInfo: *pdbInfo}
+	// con_id is 0 in a non-CDB and 1 in CDB$ROOT
+	instanceInfoAll.PdbFlag = pdbInfo.ConId != "" && pdbInfo.ConId != "0" && pdbInfo.ConId != "1"
+
+	if instanceInfo.ClusterDatabase {
+		instanceInfoAll.Instances, err = getRacInstances(ctx, dbcli)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err}).Error("Get Oracle RAC Instances Error")
+			return nil, err
+		}
+	}
 
 	return &instanceInfoAll, nil
 }
 
-func getOracleInfo(ctx context.Context, dbcli *dbutil.OracleClient) (*DbInfo, *InstanceInfo, error) {
+func getOracleInfo(ctx context.Context, dbcli dbutil.Querier) (*DbInfo, *InstanceInfo, error) {
 	instance_info, err := getInstanceInfo(ctx, dbcli)
 	if err != nil {
 		return nil, nil, err
@@ -90,58 +110,70 @@ func getOracleInfo(ctx context.Context, dbcli *dbutil.OracleClient) (*DbInfo, *I
 	return db_info, instance_info, nil
 }
 
-func getDbInfo(ctx context.Context, dbcli *dbutil.OracleClient) (*DbInfo, error) {
-	sql := `select /* dtagent */ to_char(dbid), name, db_unique_name, 
+func getDbInfo(ctx context.Context, dbcli dbutil.Querier) (*DbInfo, error) {
+	sql := `select /* dtagent */ to_char(dbid) as dbid, name, db_unique_name, 
 to_char(created, 'yyyy-mm-dd hh24:mi:ss') as created, log_mode, 
 open_mode, protection_mode, database_role, platform_name 
 from v$database`
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no row in v$database")
+	}
 
 	r := rows[0]
 
 	dbinfo := DbInfo{}
-	dbinfo.Dbid = r[0].(string)
-	dbinfo.DbName = r[1].(string)
-	dbinfo.DbUniqueName = r[2].(string)
-	dbinfo.Created = r[3].(string)
-	dbinfo.LogMode = r[4].(string)
-	dbinfo.OpenMode = r[5].(string)
-	dbinfo.ProtectionMode = r[6].(string)
-	dbinfo.DatabaseRole = r[7].(string)
-	dbinfo.PlatformName = r[8].(string)
+	dbinfo.Dbid = r.String("dbid")
+	dbinfo.DbName = r.String("name")
+	dbinfo.DbUniqueName = r.String("db_unique_name")
+	dbinfo.Created = r.String("created")
+	dbinfo.LogMode = r.String("log_mode")
+	dbinfo.OpenMode = r.String("open_mode")
+	dbinfo.ProtectionMode = r.String("protection_mode")
+	dbinfo.DatabaseRole = r.String("database_role")
+	dbinfo.PlatformName = r.String("platform_name")
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
 
 	return &dbinfo, nil
 }
 
-func getInstanceInfo(ctx context.Context, dbcli *dbutil.OracleClient) (*InstanceInfo, error) {
+func getInstanceInfo(ctx context.Context, dbcli dbutil.Querier) (*InstanceInfo, error) {
 
-	sql := `select to_char(instance_number), instance_name, host_name, version, status, 
-parallel, to_char(thread#), archiver, to_char(startup_time, 'yyyy-mm-dd hh24:mi:ss') as startup_time, 
+	sql := `select to_char(instance_number) as instance_number, instance_name, host_name, version, status, 
+parallel, to_char(thread#) as thread, archiver, to_char(startup_time, 'yyyy-mm-dd hh24:mi:ss') as startup_time, 
 (sysdate - startup_time)*86400  as uptime, instance_role, database_status 
 from v$instance`
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no row in v$instance")
+	}
 
 	r := rows[0]
 	info := InstanceInfo{}
-	info.InstanceNumber = r[0].(string)
-	info.InstanceName = r[1].(string)
-	info.HostName = r[2].(string)
-	info.Version = r[3].(string)
-	info.Status = r[4].(string)
-	info.Parallel = r[5].(string)
-	info.ThreadNum = r[6].(string)
-	info.Archiver = r[7].(string)
-	info.StartupTime = r[8].(string)
-	info.Uptime = r[9].(float64)
-	info.InstanceRole = r[10].(string)
-	info.DatabaseStatus = r[11].(string)
+	info.InstanceNumber = r.String("instance_number")
+	info.InstanceName = r.String("instance_name")
+	info.HostName = r.String("host_name")
+	info.Version = r.String("version")
+	info.Status = r.String("status")
+	info.Parallel = r.String("parallel")
+	info.ThreadNum = r.String("thread")
+	info.Archiver = r.String("archiver")
+	info.StartupTime = r.String("startup_time")
+	info.Uptime = r.Float("uptime")
+	info.InstanceRole = r.String("instance_role")
+	info.DatabaseStatus = r.String("database_status")
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
 
 	versionNum, err := parseVersion(info.Version)
 	if err != nil {
@@ -149,18 +181,57 @@ from v$instance`
 		return nil, parseErr
 	}
 	info.VersionNum = versionNum
+
+	info.Edition, err = getEdition(ctx, dbcli)
+	if err != nil {
+		return nil, err
+	}
+
+	info.ClusterDatabase, err = getClusterDatabase(ctx, dbcli)
+	if err != nil {
+		return nil, err
+	}
 	return &info, nil
 }
 
-func getPdbInfo(ctx context.Context, dbcli *dbutil.OracleClient) (*PdbInfo, error) {
-	sql := `select sys_context('userenv', 'con_name'), sys_context('userenv', 'con_id')  from dual`
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+func getEdition(ctx context.Context, dbcli dbutil.Querier) (string, error) {
+	sql := `select banner from v$version where banner like 'Oracle%'`
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("no oracle banner in v$version")
+	}
+	banner := rows[0].String("banner")
+	return parseEdition(banner), rows[0].Err()
+}
+
+// parseEdition gets the edition from the banner of v$version,
+// the banner of standard edition before 12c does not name the edition.
+func parseEdition(banner string) string {
+	switch {
+	case strings.Contains(banner, "Enterprise Edition"):
+		return "EE"
+	case strings.Contains(banner, "Express Edition"):
+		return "XE"
+	case strings.Contains(banner, "Personal"):
+		return "PE"
+	}
+	return "SE"
+}
+
+func getPdbInfo(ctx context.Context, dbcli dbutil.Querier) (*PdbInfo, error) {
+	sql := `select sys_context('userenv', 'con_name') as con_name, sys_context('userenv', 'con_id') as con_id from dual`
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no row from dual")
+	}
 
-	conName := rows[0][0].(string)
-	conId := rows[0][1].(string)
-	pdbInfo := PdbInfo{ConName: conName, ConId: conId}
-	return &pdbInfo, nil
+	r := rows[0]
+	pdbInfo := PdbInfo{ConName: r.String("con_name"), ConId: r.String("con_id")}
+	return &pdbInfo, r.Err()
 }
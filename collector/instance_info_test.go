@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+var (
+	instanceCols = []string{"instance_number", "instance_name", "host_name", "version", "status", "parallel",
+		"thread", "archiver", "startup_time", "uptime", "instance_role", "database_status"}
+	databaseCols = []string{"dbid", "name", "db_unique_name", "created", "log_mode", "open_mode",
+		"protection_mode", "database_role", "platform_name"}
+)
+
+// infoQuerier answers the queries of getOracleInfoAll on a 19c CDB, clusterDatabase is the value of
+// the cluster_database parameter, conName and conId are the container of the session.
+func infoQuerier(clusterDatabase string, conName string, conId string) *dbutil.FakeQuerier {
+	return dbutil.NewFakeQuerier().
+		Add(`from v\$instance`, instanceCols, dbutil.Row{"1", "orcl1", "db1", "19.0.0.0.0", "OPEN", "NO", "1", "STARTED",
+			"2022-09-01 10:00:00", 86400.0, "PRIMARY_INSTANCE", "ACTIVE"}).
+		Add(`from v\$version`, []string{"banner"}, dbutil.Row{"Oracle Database 19c Enterprise Edition Release 19.0.0.0.0 - Production"}).
+		Add(`from v\$database`, databaseCols, dbutil.Row{"1234567", "ORCL", "orcl", "2022-01-01 00:00:00", "ARCHIVELOG",
+			"READ WRITE", "MAXIMUM PERFORMANCE", "PRIMARY", "Linux x86 64-bit"}).
+		Add(`from v\$parameter`, []string{"value"}, dbutil.Row{clusterDatabase}).
+		Add(`from gv\$instance`, []string{"inst_id", "instance_name"}, dbutil.Row{"1", "orcl1"}, dbutil.Row{"2", "orcl2"}).
+		Add(`sys_context`, []string{"con_name", "con_id"}, dbutil.Row{conName, conId})
+}
+
+func TestGetOracleInfoAll(t *testing.T) {
+	ora, err := getOracleInfoAll(context.Background(), infoQuerier("FALSE", "CDB$ROOT", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ora.VersionNum != 19.0 || ora.Edition != "EE" || ora.OpenMode != "READ WRITE" || ora.ConName != "CDB$ROOT" || ora.ConId != "1" {
+		t.Fatalf("unexpected info: %+v", ora)
+	}
+	if ora.PdbFlag {
+		t.Fatal("CDB$ROOT as PDB")
+	}
+	if ora.ClusterDatabase || ora.Instances != nil {
+		t.Fatalf("single instance database as RAC: %+v", ora)
+	}
+
+	// the names of the instances of a RAC are read from gv$instance
+	ora, err = getOracleInfoAll(context.Background(), infoQuerier("TRUE", "CDB$ROOT", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ora.ClusterDatabase || len(ora.Instances) != 2 || ora.Instances["2"] != "orcl2" {
+		t.Fatalf("unexpected RAC info: %+v", ora)
+	}
+
+	// a target connected to the service of a PDB is in the PDB
+	ora, err = getOracleInfoAll(context.Background(), infoQuerier("FALSE", "PDB1", "3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ora.PdbFlag {
+		t.Fatalf("PDB not flagged: %+v", ora)
+	}
+
+	q := dbutil.NewFakeQuerier().AddError(`from v\$instance`, context.DeadlineExceeded)
+	if _, err := getOracleInfoAll(context.Background(), q); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
@@ -12,6 +13,9 @@ import (
 //
 var (
 	pga_stats = []string{}
+
+	pgaStatDescs = newInstanceDescs("pga", "metric from v$pgastat", nil)
+	sgaStatDescs = newInstanceDescs("sga", "metric from v$sgastat", nil)
 )
 
 type ScrapeMemoryInfo struct{}
@@ -29,27 +33,29 @@ func (ScrapeMemoryInfo) Version() float64 {
 	return 10.2
 }
 
-func (ScrapeMemoryInfo) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	if ora.PdbFlag {
-		return nil
-	}
-	err := scrape_pga(ctx, dbcli, ch)
+func (ScrapeMemoryInfo) Requirement() Requirement {
+	return Requirement{Container: ContainerRoot, Mounted: true}
+}
+
+func (ScrapeMemoryInfo) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	out := newMetricSender(ch)
+	err := scrape_pga(ctx, dbcli, out, ora)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("scrape pga has error")
 		return err
 	}
 
-	err = scrape_sga(ctx, dbcli, ch)
+	err = scrape_sga(ctx, dbcli, out, ora)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("scrape sga has error")
 		return err
 	}
-	return nil
+	return out.err()
 }
 
-func scrape_pga(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric) error {
-	sql := `select name, value from v$pgastat where unit is not null`
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+func scrape_pga(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
+	sql := fmt.Sprintf(`select %sname, value from %s where unit is not null`, instanceColumn(ora, ""), instanceView(ora, "pgastat"))
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 
 	if err != nil {
 		return err
@@ -57,21 +63,26 @@ func scrape_pga(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prome
 
 	for _, r := range rows {
 
-		stat_name := r[0].(string)
-		val := r[1].(float64)
-		desc := newDesc("pga", formatLabel(stat_name), "metric from v$pgastat")
-
-		ch <- prometheus.MustNewConstMetric(
-			desc, prometheus.GaugeValue, val,
+		stat_name := r.String("name")
+		val := r.Float("value")
+		labels := instanceLabels(ora, r)
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		desc := pgaStatDescs.get(ora, formatLabel(stat_name))
+
+		out.send(
+			desc, prometheus.GaugeValue, val, labels...,
 		)
 	}
 
 	return nil
 }
 
-func scrape_sga(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric) error {
-	sql := `select name, bytes from v$sgainfo`
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+func scrape_sga(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
+	sql := fmt.Sprintf(`select %sname, bytes from %s`, instanceColumn(ora, ""), instanceView(ora, "sgainfo"))
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 
 	if err != nil {
 		return err
@@ -79,12 +90,17 @@ func scrape_sga(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prome
 
 	for _, r := range rows {
 
-		stat_name := r[0].(string)
-		val := r[1].(float64)
-		desc := newDesc("sga", formatLabel(stat_name), "metric from v$pgastat")
-
-		ch <- prometheus.MustNewConstMetric(
-			desc, prometheus.GaugeValue, val,
+		stat_name := r.String("name")
+		val := r.Float("bytes")
+		labels := instanceLabels(ora, r)
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		desc := sgaStatDescs.get(ora, formatLabel(stat_name))
+
+		out.send(
+			desc, prometheus.GaugeValue, val, labels...,
 		)
 
 		// if stat_name == '' {
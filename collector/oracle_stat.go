@@ -53,6 +53,29 @@ var (
 	// 	prometheus.BuildFQName(namespace, "stat", "stat"),
 	// 	"Oracle Stats",
 	// 	[]string{"name"}, nil)
+
+	oracleStatDescs = newInstanceDescs("stat", "Oracle Stats", []string{"con_id", "con_name"})
+
+	oracleSessionsTotalDesc = newInstanceDesc(
+		prometheus.BuildFQName(namespace, "stat_sessions", "total"),
+		"Oracle Stats",
+		[]string{"con_id", "con_name"})
+	oracleSessionsActiveDesc = newInstanceDesc(
+		prometheus.BuildFQName(namespace, "stat_sessions", "active"),
+		"Oracle Stats",
+		[]string{"con_id", "con_name"})
+	oracleSessionsWithTransDesc = newInstanceDesc(
+		prometheus.BuildFQName(namespace, "stat_sessions", "with_trans"),
+		"Oracle Stats",
+		[]string{"con_id", "con_name"})
+	oracleSessionsBlockingDesc = newInstanceDesc(
+		prometheus.BuildFQName(namespace, "stat_sessions", "blocking"),
+		"Oracle Stats",
+		[]string{"con_id", "con_name"})
+	oracleProcessCountDesc = newInstanceDesc(
+		prometheus.BuildFQName(namespace, "stat", "process_count"),
+		"Oracle Stats",
+		[]string{"con_id", "con_name"})
 )
 
 type ScrapeOracleStat struct{}
@@ -70,142 +93,141 @@ func (ScrapeOracleStat) Version() float64 {
 	return 10.2
 }
 
-func (s ScrapeOracleStat) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+func (ScrapeOracleStat) Requirement() Requirement {
+	return Requirement{Mounted: true}
+}
+
+func (s ScrapeOracleStat) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 	var err error
-	err = s.scrapeOracleStat(ctx, dbcli, ch, ora)
+	out := newMetricSender(ch)
+	err = s.scrapeOracleStat(ctx, dbcli, out, ora)
 	if err != nil {
 		return err
 	}
 
-	err = s.scrapeSessionNumber(ctx, dbcli, ch, ora)
+	err = s.scrapeSessionNumber(ctx, dbcli, out, ora)
 	if err != nil {
 		return err
 	}
 
-	err = s.scrapeProcessNumber(ctx, dbcli, ch, ora)
+	err = s.scrapeProcessNumber(ctx, dbcli, out, ora)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return out.err()
 }
 
-func (ScrapeOracleStat) scrapeOracleStat(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+func (ScrapeOracleStat) scrapeOracleStat(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
 	var sqltext string
 	if ora.VersionNum < 12.0 {
-		sqltext = "select /* oracle_exporter */ name, value, 0 as con_id from v$sysstat where name in (%s)"
+		sqltext = "select /* oracle_exporter */ %sname, value, 0 as con_id from %s where name in (%s)"
 	} else {
-		sqltext = "select /* oracle_exporter */ name, value, con_id from v$sysstat where name in (%s)"
+		sqltext = "select /* oracle_exporter */ %sname, value, con_id from %s where name in (%s)"
 	}
-	sql := fmt.Sprintf(sqltext, formatInList(stats))
+	sql := fmt.Sprintf(sqltext, instanceColumn(ora, ""), instanceView(ora, "sysstat"), formatInList(stats))
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("Query Error")
 		return err
 	}
 	for _, r := range rows {
-		val := r[1].(float64)
-		stat := formatLabel(r[0].(string))
-		conId := r[2].(float64)
-
-		oracleStatDesc := prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "stat", stat),
-			"Oracle Stats",
-			[]string{"con_id", "con_name"}, nil)
-		ch <- prometheus.MustNewConstMetric(oracleStatDesc, prometheus.CounterValue, val, formatFloat64(conId), ora.ConName)
+		val := r.Float("value")
+		stat := formatLabel(r.String("name"))
+		labels := instanceLabels(ora, r, r.String("con_id"), ora.ConName)
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+
+		out.send(oracleStatDescs.get(ora, stat), prometheus.CounterValue, val, labels...)
 	}
 	return nil
 }
 
-func (ScrapeOracleStat) scrapeSessionNumber(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	var sql string
+func (ScrapeOracleStat) scrapeSessionNumber(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
+	var sql, groupBy string
 	if ora.VersionNum < 12.0 {
-		sql = `select count(*) as total_sessions, 
+		sql = `select %scount(*) as total_sessions, 
   sum(case when status = 'ACTIVE' and type = 'USER' then 1 else 0 end) as active_sessions,
   sum(case when taddr is not null and type = 'USER' then 1 else 0 end) as trans_sessions,
   sum(case when blocking_session is not null and type = 'USER' then 1 else 0 end) as blocking_sessions,
- 0 as con_id from v$session`
+ 0 as con_id from %s%s`
+		groupBy = instanceGroupBy(ora)
 	} else {
+		groupBy = instanceColumn(ora, "")
 		if ora.PdbFlag {
-			sql = `select count(*) as total_sessions, 
+			sql = `select %scount(*) as total_sessions, 
   sum(case when status = 'ACTIVE' and type = 'USER' then 1 else 0 end) as active_sessions,
   sum(case when taddr is not null and type = 'USER' then 1 else 0 end) as trans_sessions,
   sum(case when blocking_session is not null and type = 'USER' then 1 else 0 end) as blocking_sessions,
- con_id from v$session where con_id > 0
- group by con_id`
+ con_id from %s where con_id > 0
+ group by %scon_id`
 		} else {
-			sql = `select count(*) as total_sessions, 
+			sql = `select %scount(*) as total_sessions, 
   sum(case when status = 'ACTIVE' and type = 'USER' then 1 else 0 end) as active_sessions,
   sum(case when taddr is not null and type = 'USER' then 1 else 0 end) as trans_sessions,
   sum(case when blocking_session is not null and type = 'USER' then 1 else 0 end) as blocking_sessions,
- con_id from v$session
- group by con_id`
+ con_id from %s
+ group by %scon_id`
 		}
 	}
+	sql = fmt.Sprintf(sql, instanceColumn(ora, ""), instanceView(ora, "session"), groupBy)
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return err
 	}
 
 	for _, r := range rows {
-		conId := r[4].(float64)
-
-		var oracleStatDesc *prometheus.Desc
-
-		oracleStatDesc = prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "stat_sessions", "total"),
-			"Oracle Stats",
-			[]string{"con_id", "con_name"}, nil)
-		ch <- prometheus.MustNewConstMetric(oracleStatDesc, prometheus.GaugeValue, r[0].(float64), formatFloat64(conId), ora.ConName)
-
-		oracleStatDesc = prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "stat_sessions", "active"),
-			"Oracle Stats",
-			[]string{"con_id", "con_name"}, nil)
-		ch <- prometheus.MustNewConstMetric(oracleStatDesc, prometheus.GaugeValue, r[1].(float64), formatFloat64(conId), ora.ConName)
-
-		oracleStatDesc = prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "stat_sessions", "with_trans"),
-			"Oracle Stats",
-			[]string{"con_id", "con_name"}, nil)
-		ch <- prometheus.MustNewConstMetric(oracleStatDesc, prometheus.GaugeValue, r[2].(float64), formatFloat64(conId), ora.ConName)
-
-		oracleStatDesc = prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "stat_sessions", "blocking"),
-			"Oracle Stats",
-			[]string{"con_id", "con_name"}, nil)
-		ch <- prometheus.MustNewConstMetric(oracleStatDesc, prometheus.GaugeValue, r[3].(float64), formatFloat64(conId), ora.ConName)
+		row := out.row()
+		labels := instanceLabels(ora, r, r.String("con_id"), ora.ConName)
+		total := r.Float("total_sessions")
+		active := r.Float("active_sessions")
+		trans := r.Float("trans_sessions")
+		blocking := r.Float("blocking_sessions")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 
+		row.send(oracleSessionsTotalDesc.get(ora), prometheus.GaugeValue, total, labels...)
+		row.send(oracleSessionsActiveDesc.get(ora), prometheus.GaugeValue, active, labels...)
+		row.send(oracleSessionsWithTransDesc.get(ora), prometheus.GaugeValue, trans, labels...)
+		row.send(oracleSessionsBlockingDesc.get(ora), prometheus.GaugeValue, blocking, labels...)
 	}
 
 	return nil
 }
 
-func (ScrapeOracleStat) scrapeProcessNumber(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	var sql string
+func (ScrapeOracleStat) scrapeProcessNumber(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
+	var sql, groupBy string
 	if ora.VersionNum < 12.0 {
-		sql = `select count(*), 0 as con_id from v$process`
+		sql = `select %scount(*) as process_count, 0 as con_id from %s%s`
+		groupBy = instanceGroupBy(ora)
 	} else {
+		groupBy = instanceColumn(ora, "")
 		if ora.PdbFlag {
-			sql = `select count(*), con_id from v$process where con_id > 0 group by con_id`
+			sql = `select %scount(*) as process_count, con_id from %s where con_id > 0 group by %scon_id`
 		} else {
-			sql = `select count(*), con_id from v$process group by con_id`
+			sql = `select %scount(*) as process_count, con_id from %s group by %scon_id`
 		}
 	}
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	sql = fmt.Sprintf(sql, instanceColumn(ora, ""), instanceView(ora, "process"), groupBy)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return err
 	}
 
 	for _, r := range rows {
-		conId := r[1].(float64)
-		oracleStatDesc := prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "stat", "process_count"),
-			"Oracle Stats",
-			[]string{"con_id", "con_name"}, nil)
-		ch <- prometheus.MustNewConstMetric(oracleStatDesc, prometheus.GaugeValue, r[0].(float64), formatFloat64(conId), ora.ConName)
+		labels := instanceLabels(ora, r, r.String("con_id"), ora.ConName)
+		processCount := r.Float("process_count")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		out.send(oracleProcessCountDesc.get(ora), prometheus.GaugeValue, processCount, labels...)
 	}
 	return nil
 }
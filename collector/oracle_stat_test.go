@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+var (
+	sysstatCols      = []string{"name", "value", "con_id"}
+	sessionCountCols = []string{"total_sessions", "active_sessions", "trans_sessions", "blocking_sessions", "con_id"}
+	processCountCols = []string{"process_count", "con_id"}
+)
+
+func TestScrapeOracleStat(t *testing.T) {
+	ora := &InstanceInfoAll{InstanceInfo: InstanceInfo{VersionNum: 11.2}, PdbInfo: PdbInfo{ConName: ""}}
+	q := dbutil.NewFakeQuerier().
+		Add(`from v\$sysstat`, sysstatCols,
+			dbutil.Row{"user commits", 120.0, 0.0},
+			dbutil.Row{"parse count (hard)", 7.0, 0.0}).
+		Add(`from v\$session`, sessionCountCols, dbutil.Row{30.0, 2.0, 1.0, 0.0, 0.0}).
+		Add(`from v\$process`, processCountCols, dbutil.Row{45.0, 0.0})
+
+	testScrape(t, ScrapeOracleStat{}, q, ora, `
+# HELP oracle_stat_parse_count_hard Oracle Stats
+# TYPE oracle_stat_parse_count_hard counter
+oracle_stat_parse_count_hard{con_id="0",con_name=""} 7
+# HELP oracle_stat_process_count Oracle Stats
+# TYPE oracle_stat_process_count gauge
+oracle_stat_process_count{con_id="0",con_name=""} 45
+# HELP oracle_stat_sessions_active Oracle Stats
+# TYPE oracle_stat_sessions_active gauge
+oracle_stat_sessions_active{con_id="0",con_name=""} 2
+# HELP oracle_stat_sessions_blocking Oracle Stats
+# TYPE oracle_stat_sessions_blocking gauge
+oracle_stat_sessions_blocking{con_id="0",con_name=""} 0
+# HELP oracle_stat_sessions_total Oracle Stats
+# TYPE oracle_stat_sessions_total gauge
+oracle_stat_sessions_total{con_id="0",con_name=""} 30
+# HELP oracle_stat_sessions_with_trans Oracle Stats
+# TYPE oracle_stat_sessions_with_trans gauge
+oracle_stat_sessions_with_trans{con_id="0",con_name=""} 1
+# HELP oracle_stat_user_commits Oracle Stats
+# TYPE oracle_stat_user_commits counter
+oracle_stat_user_commits{con_id="0",con_name=""} 120
+`)
+
+	// the queries of 11g have no con_id column
+	for _, query := range q.Queries() {
+		if !strings.Contains(query, "0 as con_id") {
+			t.Errorf("11g query with con_id column: %s", query)
+		}
+	}
+}
+
+func TestScrapeOracleStatPdb(t *testing.T) {
+	ora := &InstanceInfoAll{InstanceInfo: InstanceInfo{VersionNum: 19.0}, PdbInfo: PdbInfo{ConName: "PDB1", ConId: "3"}, PdbFlag: true}
+	q := dbutil.NewFakeQuerier().
+		Add(`from v\$sysstat`, sysstatCols, dbutil.Row{"user commits", 5.0, 3.0}).
+		Add(`from v\$session where con_id > 0`, sessionCountCols, dbutil.Row{4.0, 1.0, 0.0, 0.0, 3.0}).
+		Add(`from v\$process where con_id > 0`, processCountCols, dbutil.Row{3.0, 3.0})
+
+	testScrape(t, ScrapeOracleStat{}, q, ora, `
+# HELP oracle_stat_process_count Oracle Stats
+# TYPE oracle_stat_process_count gauge
+oracle_stat_process_count{con_id="3",con_name="PDB1"} 3
+# HELP oracle_stat_sessions_active Oracle Stats
+# TYPE oracle_stat_sessions_active gauge
+oracle_stat_sessions_active{con_id="3",con_name="PDB1"} 1
+# HELP oracle_stat_sessions_blocking Oracle Stats
+# TYPE oracle_stat_sessions_blocking gauge
+oracle_stat_sessions_blocking{con_id="3",con_name="PDB1"} 0
+# HELP oracle_stat_sessions_total Oracle Stats
+# TYPE oracle_stat_sessions_total gauge
+oracle_stat_sessions_total{con_id="3",con_name="PDB1"} 4
+# HELP oracle_stat_sessions_with_trans Oracle Stats
+# TYPE oracle_stat_sessions_with_trans gauge
+oracle_stat_sessions_with_trans{con_id="3",con_name="PDB1"} 0
+# HELP oracle_stat_user_commits Oracle Stats
+# TYPE oracle_stat_user_commits counter
+oracle_stat_user_commits{con_id="3",con_name="PDB1"} 5
+`)
+}
@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"fmt"
 	"regexp"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -9,10 +10,12 @@ import (
 )
 
 var (
-	oracleOsStatCpuDesc = prometheus.NewDesc(
+	oracleOsStatCpuDesc = newInstanceDesc(
 		prometheus.BuildFQName(namespace, "osstat", "cpu_total"),
 		"Oracle OS Stats Cpu Total",
-		[]string{"mode"}, nil)
+		[]string{"mode"})
+
+	oracleOsStatDescs = newInstanceDescs("osstat", "Metric from v$osstat", nil)
 
 	regCpu = regexp.MustCompile(`(\w+)_time`)
 )
@@ -32,11 +35,12 @@ func (ScrapeOracleOsStat) Version() float64 {
 	return 10.2
 }
 
-func (ScrapeOracleOsStat) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	if ora.PdbFlag {
-		return nil
-	}
-	sql := `select lower(stat_name) as stat_name, value from v$osstat
+func (ScrapeOracleOsStat) Requirement() Requirement {
+	return Requirement{Container: ContainerRoot, Mounted: true}
+}
+
+func (ScrapeOracleOsStat) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	sql := `select %slower(stat_name) as stat_name, value from %s
 where stat_name in (
   'NUM_CPUS', 
   'IDLE_TIME', 
@@ -50,25 +54,32 @@ where stat_name in (
   'NUM_CPU_CORES',
   'NUM_CPU_SOCKETS'
   )`
+	sql = fmt.Sprintf(sql, instanceColumn(ora, ""), instanceView(ora, "osstat"))
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return err
 	}
+	out := newMetricSender(ch)
 	for _, r := range rows {
-		stat_name := r[0].(string)
-		val := r[1].(float64)
+		stat_name := r.String("stat_name")
+		val := r.Float("value")
+		labels := instanceLabels(ora, r)
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 
 		match := regCpu.FindStringSubmatch(stat_name)
 		if match == nil {
-			ch <- prometheus.MustNewConstMetric(
-				newDesc("osstat", stat_name, "Metric from v$osstat"), prometheus.GaugeValue, val)
+			out.send(
+				oracleOsStatDescs.get(ora, stat_name), prometheus.GaugeValue, val, labels...)
 
 			continue
 		}
-		ch <- prometheus.MustNewConstMetric(
-			oracleOsStatCpuDesc, prometheus.CounterValue, val, match[1])
+		out.send(
+			oracleOsStatCpuDesc.get(ora), prometheus.CounterValue, val, append([]string{match[1]}, labels...)...)
 
 	}
-	return nil
+	return out.err()
 }
@@ -48,21 +48,27 @@ func (ScrapeOracleParameter) Version() float64 {
 	return 10.2
 }
 
-func (ScrapeOracleParameter) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	if ora.PdbFlag {
-		return nil
-	}
+func (ScrapeOracleParameter) Requirement() Requirement {
+	return Requirement{Container: ContainerRoot, Mounted: true}
+}
+
+func (ScrapeOracleParameter) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 	sqltext := "select name, value from v$parameter where name in (%s)"
 	sql := fmt.Sprintf(sqltext, formatInList(params))
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("Query Error")
 		return err
 	}
+	out := newMetricSender(ch)
 	for _, r := range rows {
-		param_name := r[0].(string)
-		str_val := r[1].(string)
+		param_name := r.String("name")
+		str_val := r.String("value")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 		val, err := strconv.ParseFloat(str_val, 64)
 		if err != nil {
 			log.WithFields(log.Fields{"str_val": str_val}).Info("can not parse float")
@@ -71,7 +77,7 @@ func (ScrapeOracleParameter) Scrape(ctx context.Context, dbcli *dbutil.OracleCli
 
 		oracleParmsDesc := newDesc("param", param_name, "oracle param")
 
-		ch <- prometheus.MustNewConstMetric(oracleParmsDesc, prometheus.UntypedValue, val)
+		out.send(oracleParmsDesc, prometheus.UntypedValue, val)
 	}
-	return nil
+	return out.err()
 }
@@ -0,0 +1,25 @@
+package collector
+
+import (
+	"testing"
+
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+func TestScrapeOracleParameter(t *testing.T) {
+	q := dbutil.NewFakeQuerier().
+		Add(`from v\$parameter`, []string{"name", "value"},
+			dbutil.Row{"processes", "300"},
+			dbutil.Row{"sga_target", "1073741824"},
+			// values which are not numbers are skipped
+			dbutil.Row{"memory_target", "big"})
+
+	testScrape(t, ScrapeOracleParameter{}, q, &InstanceInfoAll{}, `
+# HELP oracle_param_processes oracle param
+# TYPE oracle_param_processes untyped
+oracle_param_processes 300
+# HELP oracle_param_sga_target oracle param
+# TYPE oracle_param_sga_target untyped
+oracle_param_sga_target 1.073741824e+09
+`)
+}
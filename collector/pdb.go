@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+const pdbDiscoveryCollector = "pdb_discovery"
+
+var (
+	oraclePdbInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pdb", "info"),
+		"Oracle PDB Info",
+		[]string{"con_id", "pdb_name", "open_mode", "restricted"}, nil)
+
+	oraclePdbTotalSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pdb", "total_size_bytes"),
+		"Oracle PDB Total Size",
+		[]string{"con_id", "pdb_name"}, nil)
+)
+
+type PdbStat struct {
+	ConId      string
+	Name       string
+	OpenMode   string
+	Restricted string
+	TotalSize  float64
+}
+
+// opened reports whether sessions can be opened in the PDB.
+func (p *PdbStat) opened() bool {
+	return strings.HasPrefix(p.OpenMode, "READ")
+}
+
+// getPdbs returns the PDBs, rows which can not be read are skipped by out.
+func getPdbs(ctx context.Context, dbcli dbutil.Querier, out *metricSender) ([]*PdbStat, error) {
+	sql := `select to_char(con_id) as con_id, name, open_mode, nvl(restricted, 'NO') as restricted, total_size
+from v$pdbs
+where name <> 'PDB$SEED'`
+
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	var pdbs []*PdbStat
+	for _, r := range rows {
+		pdb := PdbStat{
+			ConId:      r.String("con_id"),
+			Name:       r.String("name"),
+			OpenMode:   r.String("open_mode"),
+			Restricted: r.String("restricted"),
+			TotalSize:  r.Float("total_size"),
+		}
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		pdbs = append(pdbs, &pdb)
+	}
+	return pdbs, nil
+}
+
+// discoverPdbs returns the open PDBs matching the include/exclude patterns of the config
+// and sends the info of all PDBs. Rows of v$pdbs which can not be read are skipped,
+// the PDBs found are returned with a *BadRowsError.
+func discoverPdbs(ctx context.Context, dbcli dbutil.Querier, discovery dbutil.PdbDiscoveryConfig, ch chan<- prometheus.Metric) ([]string, error) {
+	var include, exclude *regexp.Regexp
+	var err error
+	if discovery.Include != "" {
+		include, err = regexp.Compile(discovery.Include)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if discovery.Exclude != "" {
+		exclude, err = regexp.Compile(discovery.Exclude)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := newMetricSender(ch)
+	pdbs, err := getPdbs(ctx, dbcli, out)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, pdb := range pdbs {
+		row := out.row()
+		row.send(oraclePdbInfoDesc, prometheus.GaugeValue, 1,
+			pdb.ConId, pdb.Name, pdb.OpenMode, pdb.Restricted)
+		row.send(oraclePdbTotalSizeDesc, prometheus.GaugeValue, pdb.TotalSize,
+			pdb.ConId, pdb.Name)
+
+		if !pdb.opened() {
+			log.WithFields(log.Fields{"pdb": pdb.Name, "open_mode": pdb.OpenMode}).Debug("skip pdb not opened")
+			continue
+		}
+		if include != nil && !include.MatchString(pdb.Name) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(pdb.Name) {
+			continue
+		}
+		names = append(names, pdb.Name)
+	}
+	return names, out.err()
+}
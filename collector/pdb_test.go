@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+func pdbsQuerier() *dbutil.FakeQuerier {
+	return dbutil.NewFakeQuerier().
+		Add(`from v\$pdbs`, []string{"con_id", "name", "open_mode", "restricted", "total_size"},
+			dbutil.Row{"3", "SALES", "READ WRITE", "NO", 1024.0},
+			dbutil.Row{"4", "SALES_TEST", "READ WRITE", "NO", 2048.0},
+			dbutil.Row{"5", "HR", "READ ONLY", "YES", 512.0},
+			dbutil.Row{"6", "OLD", "MOUNTED", "NO", 0.0})
+}
+
+func TestDiscoverPdbs(t *testing.T) {
+	cases := []struct {
+		name      string
+		discovery dbutil.PdbDiscoveryConfig
+		want      []string
+	}{
+		{"all", dbutil.PdbDiscoveryConfig{}, []string{"SALES", "SALES_TEST", "HR"}},
+		{"include", dbutil.PdbDiscoveryConfig{Include: `^SALES`}, []string{"SALES", "SALES_TEST"}},
+		{"exclude", dbutil.PdbDiscoveryConfig{Exclude: `_TEST$`}, []string{"SALES", "HR"}},
+		{"include and exclude", dbutil.PdbDiscoveryConfig{Include: `^SALES`, Exclude: `_TEST$`}, []string{"SALES"}},
+		{"not opened", dbutil.PdbDiscoveryConfig{Include: `^OLD$`}, nil},
+	}
+	for _, c := range cases {
+		var names []string
+		_, err := collectMetrics(func(ch chan<- prometheus.Metric) error {
+			var err error
+			names, err = discoverPdbs(context.Background(), pdbsQuerier(), c.discovery, ch)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(names, c.want) {
+			t.Errorf("%s: discovered %v, want %v", c.name, names, c.want)
+		}
+	}
+}
+
+func TestDiscoverPdbsInvalidPattern(t *testing.T) {
+	_, err := collectMetrics(func(ch chan<- prometheus.Metric) error {
+		_, err := discoverPdbs(context.Background(), pdbsQuerier(), dbutil.PdbDiscoveryConfig{Include: `(`}, ch)
+		return err
+	})
+	if err == nil {
+		t.Fatal("invalid include pattern accepted")
+	}
+}
+
+// discoverCollector sends the metrics of discoverPdbs, to compare them with testutil.
+type discoverCollector struct {
+	q *dbutil.FakeQuerier
+}
+
+func (c discoverCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c discoverCollector) Collect(ch chan<- prometheus.Metric) {
+	discoverPdbs(context.Background(), c.q, dbutil.PdbDiscoveryConfig{Include: `^SALES$`}, ch)
+}
+
+func TestDiscoverPdbsInfo(t *testing.T) {
+	// the info of all PDBs is sent, whether they are scraped or not
+	expected := `
+# HELP oracle_pdb_info Oracle PDB Info
+# TYPE oracle_pdb_info gauge
+oracle_pdb_info{con_id="3",open_mode="READ WRITE",pdb_name="SALES",restricted="NO"} 1
+oracle_pdb_info{con_id="4",open_mode="READ WRITE",pdb_name="SALES_TEST",restricted="NO"} 1
+oracle_pdb_info{con_id="5",open_mode="READ ONLY",pdb_name="HR",restricted="YES"} 1
+oracle_pdb_info{con_id="6",open_mode="MOUNTED",pdb_name="OLD",restricted="NO"} 1
+# HELP oracle_pdb_total_size_bytes Oracle PDB Total Size
+# TYPE oracle_pdb_total_size_bytes gauge
+oracle_pdb_total_size_bytes{con_id="3",pdb_name="SALES"} 1024
+oracle_pdb_total_size_bytes{con_id="4",pdb_name="SALES_TEST"} 2048
+oracle_pdb_total_size_bytes{con_id="5",pdb_name="HR"} 512
+oracle_pdb_total_size_bytes{con_id="6",pdb_name="OLD"} 0
+`
+	if err := testutil.CollectAndCompare(discoverCollector{pdbsQuerier()}, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPdbsToScrapeFallback(t *testing.T) {
+	cfg := dbutil.OracleConfig{
+		Pdbs:         []string{"PDB1", "PDB2"},
+		PdbDiscovery: dbutil.PdbDiscoveryConfig{Enabled: true},
+	}
+	target := &Target{Client: dbutil.NewOracleClient(cfg), Metrics: NewMetrics(), cache: newScrapeCache()}
+	e := New(context.Background(), nil, target, &Config{})
+
+	var pdbs []string
+	collectMetrics(func(ch chan<- prometheus.Metric) error {
+		pdbs = e.pdbsToScrape(context.Background(), pdbsQuerier(), ch)
+		return nil
+	})
+	if want := []string{"SALES", "SALES_TEST", "HR"}; !reflect.DeepEqual(pdbs, want) {
+		t.Errorf("discovered %v, want %v", pdbs, want)
+	}
+
+	// the configured pdbs are scraped when the discovery query fails
+	err := errors.New("ORA-00942: table or view does not exist")
+	q := dbutil.NewFakeQuerier().AddError(`from v\$pdbs`, err)
+	collectMetrics(func(ch chan<- prometheus.Metric) error {
+		pdbs = e.pdbsToScrape(context.Background(), q, ch)
+		return nil
+	})
+	if !reflect.DeepEqual(pdbs, cfg.Pdbs) {
+		t.Errorf("scraped %v after a failed discovery, want the configured %v", pdbs, cfg.Pdbs)
+	}
+	if n := testutil.ToFloat64(e.metrics.ScrapeErrors.WithLabelValues(pdbDiscoveryCollector, "", errorCode(err))); n != 1 {
+		t.Errorf("recorded %v discovery errors, want 1", n)
+	}
+}
@@ -0,0 +1,306 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// In RAC mode the instance level collectors query the gv$ views once for all instances of the cluster,
+// instead of the v$ views of the connected instance, and label their metrics with the instance.
+
+// labels added to the metrics of the instance level collectors in RAC mode
+var racLabels = []string{"inst_id", "instance_name"}
+
+// the global cache stats of v$sysstat, the receive time is in centiseconds
+var (
+	gcStats = []string{
+		"gc cr blocks received",
+		"gc cr block receive time",
+		"gc current blocks received",
+		"gc current block receive time",
+	}
+
+	// the waits of the blocks transferred through the interconnect
+	gcEvents = []string{
+		"gc cr block 2-way",
+		"gc cr block 3-way",
+		"gc current block 2-way",
+		"gc current block 3-way",
+		"gc cr block busy",
+		"gc current block busy",
+		"gc cr block congested",
+		"gc current block congested",
+	}
+
+	racInstanceStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "rac", "instance_status"),
+		"Status of the instances of the cluster in gv$instance, 1 if OPEN and ACTIVE.",
+		[]string{"inst_id", "instance_name", "host_name", "status", "database_status"}, nil)
+
+	racInstanceUptimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "rac", "instance_uptime_seconds"),
+		"Time since the startup of the instances of the cluster.",
+		racLabels, nil)
+
+	racGcBlocksReceivedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "rac", "gc_blocks_received_total"),
+		"Global cache blocks received through the interconnect, by block type cr or current.",
+		[]string{"inst_id", "instance_name", "type"}, nil)
+
+	racGcReceiveTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "rac", "gc_block_receive_time_seconds_total"),
+		"Time spent receiving global cache blocks, by block type cr or current.",
+		[]string{"inst_id", "instance_name", "type"}, nil)
+
+	racGcWaitsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "rac", "gc_waits_total"),
+		"Waits of the global cache events of the interconnect.",
+		[]string{"inst_id", "instance_name", "event"}, nil)
+
+	racGcWaitTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "rac", "gc_wait_time_seconds_total"),
+		"Time waited on the global cache events of the interconnect.",
+		[]string{"inst_id", "instance_name", "event"}, nil)
+)
+
+// instanceView returns the view of the instance level stats named like sysstat: v$sysstat of the
+// connected instance, or gv$sysstat of all instances in RAC mode.
+func instanceView(ora *InstanceInfoAll, name string) string {
+	if ora.Rac {
+		return "gv$" + name
+	}
+	return "v$" + name
+}
+
+// instanceColumn selects the inst_id column of a gv$ view in RAC mode, alias is the prefix of the
+// column like "a.", the column is followed by a comma.
+func instanceColumn(ora *InstanceInfoAll, alias string) string {
+	if ora.Rac {
+		return alias + "inst_id, "
+	}
+	return ""
+}
+
+// instanceGroupBy groups the rows of a gv$ view by instance in RAC mode, for an aggregate query without group by.
+func instanceGroupBy(ora *InstanceInfoAll) string {
+	if ora.Rac {
+		return " group by inst_id"
+	}
+	return ""
+}
+
+// instanceLabels appends the inst_id of the row and its instance name to the label values in RAC mode.
+func instanceLabels(ora *InstanceInfoAll, r *dbutil.NamedRow, values ...string) []string {
+	if ora.Rac {
+		instId := r.String("inst_id")
+		return append(values, instId, ora.Instances[instId])
+	}
+	return values
+}
+
+// instanceDesc is the desc of a metric of an instance level collector, with the instance labels in RAC mode.
+type instanceDesc struct {
+	desc    *prometheus.Desc
+	racDesc *prometheus.Desc
+}
+
+func newInstanceDesc(fqName string, help string, labels []string) instanceDesc {
+	return instanceDesc{
+		desc:    prometheus.NewDesc(fqName, help, labels, nil),
+		racDesc: prometheus.NewDesc(fqName, help, append(labels[:len(labels):len(labels)], racLabels...), nil),
+	}
+}
+
+func (d instanceDesc) get(ora *InstanceInfoAll) *prometheus.Desc {
+	if ora.Rac {
+		return d.racDesc
+	}
+	return d.desc
+}
+
+// instanceDescs are the descs of the metrics of an instance level collector named by the stats of a
+// view, like the pga stats of v$pgastat, each desc is built once by the name of its stat.
+type instanceDescs struct {
+	subsystem string
+	help      string
+	labels    []string
+
+	mu    sync.Mutex
+	descs map[string]instanceDesc
+}
+
+func newInstanceDescs(subsystem string, help string, labels []string) *instanceDescs {
+	return &instanceDescs{subsystem: subsystem, help: help, labels: labels, descs: make(map[string]instanceDesc)}
+}
+
+func (d *instanceDescs) get(ora *InstanceInfoAll, name string) *prometheus.Desc {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	desc, found := d.descs[name]
+	if !found {
+		desc = newInstanceDesc(prometheus.BuildFQName(namespace, d.subsystem, name), d.help, d.labels)
+		d.descs[name] = desc
+	}
+	return desc.get(ora)
+}
+
+// getClusterDatabase returns whether the database is a RAC.
+func getClusterDatabase(ctx context.Context, dbcli dbutil.Querier) (bool, error) {
+	sql := `select value from v$parameter where name = 'cluster_database'`
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
+	if err != nil {
+		return false, err
+	}
+	if len(rows) == 0 {
+		return false, nil
+	}
+	value := rows[0].String("value")
+	return value == "TRUE", rows[0].Err()
+}
+
+// getRacInstances returns the names of the running instances of the cluster by inst_id.
+func getRacInstances(ctx context.Context, dbcli dbutil.Querier) (map[string]string, error) {
+	sql := `select to_char(inst_id) as inst_id, instance_name from gv$instance`
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
+	if err != nil {
+		return nil, err
+	}
+	instances := make(map[string]string, len(rows))
+	for _, r := range rows {
+		instId := r.String("inst_id")
+		name := r.String("instance_name")
+		if err := r.Err(); err != nil {
+			return nil, err
+		}
+		instances[instId] = name
+	}
+	return instances, nil
+}
+
+type ScrapeOracleRac struct{}
+
+func (ScrapeOracleRac) Name() string {
+	return "oracle_rac"
+}
+
+func (ScrapeOracleRac) Help() string {
+	return "collect instance status and interconnect global cache stats from gv$instance, gv$sysstat, gv$system_event"
+}
+
+func (ScrapeOracleRac) Version() float64 {
+	return 10.2
+}
+
+func (ScrapeOracleRac) Requirement() Requirement {
+	return Requirement{Container: ContainerRoot, Mounted: true, Rac: true}
+}
+
+func (s ScrapeOracleRac) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	out := newMetricSender(ch)
+	err := s.scrapeInstances(ctx, dbcli, out)
+	if err != nil {
+		return err
+	}
+
+	err = s.scrapeGcStats(ctx, dbcli, out, ora)
+	if err != nil {
+		return err
+	}
+
+	err = s.scrapeGcEvents(ctx, dbcli, out, ora)
+	if err != nil {
+		return err
+	}
+	return out.err()
+}
+
+func (ScrapeOracleRac) scrapeInstances(ctx context.Context, dbcli dbutil.Querier, out *metricSender) error {
+	sql := `select to_char(inst_id) as inst_id, instance_name, host_name, status, database_status,
+(sysdate - startup_time)*86400 as uptime
+from gv$instance`
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		row := out.row()
+		instId := r.String("inst_id")
+		name := r.String("instance_name")
+		hostName := r.String("host_name")
+		status := r.String("status")
+		dbStatus := r.String("database_status")
+		uptime := r.Float("uptime")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		up := 0.0
+		if status == "OPEN" && dbStatus == "ACTIVE" {
+			up = 1
+		}
+		row.send(racInstanceStatusDesc, prometheus.GaugeValue, up, instId, name, hostName, status, dbStatus)
+		row.send(racInstanceUptimeDesc, prometheus.GaugeValue, uptime, instId, name)
+	}
+	return nil
+}
+
+func (ScrapeOracleRac) scrapeGcStats(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
+	sql := fmt.Sprintf(`select to_char(inst_id) as inst_id, name, sum(value) as value from gv$sysstat
+where name in (%s)
+group by inst_id, name`, formatInList(gcStats))
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		instId := r.String("inst_id")
+		name := r.String("name")
+		val := r.Float("value")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		instName := ora.Instances[instId]
+		switch name {
+		case "gc cr blocks received":
+			out.send(racGcBlocksReceivedDesc, prometheus.CounterValue, val, instId, instName, "cr")
+		case "gc current blocks received":
+			out.send(racGcBlocksReceivedDesc, prometheus.CounterValue, val, instId, instName, "current")
+		case "gc cr block receive time":
+			out.send(racGcReceiveTimeDesc, prometheus.CounterValue, val/100, instId, instName, "cr")
+		case "gc current block receive time":
+			out.send(racGcReceiveTimeDesc, prometheus.CounterValue, val/100, instId, instName, "current")
+		}
+	}
+	return nil
+}
+
+func (ScrapeOracleRac) scrapeGcEvents(ctx context.Context, dbcli dbutil.Querier, out *metricSender, ora *InstanceInfoAll) error {
+	sql := fmt.Sprintf(`select to_char(inst_id) as inst_id, event, sum(total_waits) as total_waits, sum(time_waited_micro) as time_waited_micro
+from gv$system_event
+where event in (%s)
+group by inst_id, event`, formatInList(gcEvents))
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		row := out.row()
+		instId := r.String("inst_id")
+		event := r.String("event")
+		waits := r.Float("total_waits")
+		waited := r.Float("time_waited_micro")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		instName := ora.Instances[instId]
+		row.send(racGcWaitsDesc, prometheus.CounterValue, waits, instId, instName, event)
+		row.send(racGcWaitTimeDesc, prometheus.CounterValue, waited/1e6, instId, instName, event)
+	}
+	return nil
+}
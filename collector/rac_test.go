@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+var racOra = &InstanceInfoAll{
+	InstanceInfo: InstanceInfo{VersionNum: 11.2, Edition: "EE", ClusterDatabase: true},
+	Rac:          true,
+	Instances:    map[string]string{"1": "orcl1", "2": "orcl2"},
+}
+
+func TestScrapeOracleStatRac(t *testing.T) {
+	q := dbutil.NewFakeQuerier().
+		Add(`from gv\$sysstat`, []string{"inst_id", "name", "value", "con_id"},
+			dbutil.Row{1.0, "user commits", 120.0, 0.0},
+			dbutil.Row{2.0, "user commits", 80.0, 0.0}).
+		Add(`from gv\$session group by inst_id`, []string{"inst_id", "total_sessions", "active_sessions", "trans_sessions", "blocking_sessions", "con_id"},
+			dbutil.Row{1.0, 30.0, 2.0, 1.0, 0.0, 0.0},
+			dbutil.Row{2.0, 20.0, 1.0, 0.0, 0.0, 0.0}).
+		Add(`from gv\$process group by inst_id`, []string{"inst_id", "process_count", "con_id"},
+			dbutil.Row{1.0, 45.0, 0.0},
+			dbutil.Row{2.0, 40.0, 0.0})
+
+	testScrape(t, ScrapeOracleStat{}, q, racOra, `
+# HELP oracle_stat_process_count Oracle Stats
+# TYPE oracle_stat_process_count gauge
+oracle_stat_process_count{con_id="0",con_name="",inst_id="1",instance_name="orcl1"} 45
+oracle_stat_process_count{con_id="0",con_name="",inst_id="2",instance_name="orcl2"} 40
+# HELP oracle_stat_sessions_active Oracle Stats
+# TYPE oracle_stat_sessions_active gauge
+oracle_stat_sessions_active{con_id="0",con_name="",inst_id="1",instance_name="orcl1"} 2
+oracle_stat_sessions_active{con_id="0",con_name="",inst_id="2",instance_name="orcl2"} 1
+# HELP oracle_stat_sessions_blocking Oracle Stats
+# TYPE oracle_stat_sessions_blocking gauge
+oracle_stat_sessions_blocking{con_id="0",con_name="",inst_id="1",instance_name="orcl1"} 0
+oracle_stat_sessions_blocking{con_id="0",con_name="",inst_id="2",instance_name="orcl2"} 0
+# HELP oracle_stat_sessions_total Oracle Stats
+# TYPE oracle_stat_sessions_total gauge
+oracle_stat_sessions_total{con_id="0",con_name="",inst_id="1",instance_name="orcl1"} 30
+oracle_stat_sessions_total{con_id="0",con_name="",inst_id="2",instance_name="orcl2"} 20
+# HELP oracle_stat_sessions_with_trans Oracle Stats
+# TYPE oracle_stat_sessions_with_trans gauge
+oracle_stat_sessions_with_trans{con_id="0",con_name="",inst_id="1",instance_name="orcl1"} 1
+oracle_stat_sessions_with_trans{con_id="0",con_name="",inst_id="2",instance_name="orcl2"} 0
+# HELP oracle_stat_user_commits Oracle Stats
+# TYPE oracle_stat_user_commits counter
+oracle_stat_user_commits{con_id="0",con_name="",inst_id="1",instance_name="orcl1"} 120
+oracle_stat_user_commits{con_id="0",con_name="",inst_id="2",instance_name="orcl2"} 80
+`)
+
+	// the stats of all instances are queried once
+	for _, query := range q.Queries() {
+		if !strings.Contains(query, " inst_id, ") || !strings.Contains(query, "from gv$") {
+			t.Errorf("RAC query without inst_id: %s", query)
+		}
+	}
+}
+
+func TestScrapeOracleRac(t *testing.T) {
+	q := dbutil.NewFakeQuerier().
+		Add(`from gv\$instance`, []string{"inst_id", "instance_name", "host_name", "status", "database_status", "uptime"},
+			dbutil.Row{"1", "orcl1", "db1", "OPEN", "ACTIVE", 86400.0},
+			dbutil.Row{"2", "orcl2", "db2", "MOUNTED", "ACTIVE", 60.0}).
+		Add(`from gv\$sysstat`, []string{"inst_id", "name", "value"},
+			dbutil.Row{"1", "gc cr blocks received", 1000.0},
+			dbutil.Row{"1", "gc cr block receive time", 50.0},
+			dbutil.Row{"1", "gc current blocks received", 400.0},
+			dbutil.Row{"1", "gc current block receive time", 30.0}).
+		Add(`from gv\$system_event`, []string{"inst_id", "event", "total_waits", "time_waited_micro"},
+			dbutil.Row{"2", "gc cr block 2-way", 500.0, 250000.0})
+
+	testScrape(t, ScrapeOracleRac{}, q, racOra, `
+# HELP oracle_rac_gc_block_receive_time_seconds_total Time spent receiving global cache blocks, by block type cr or current.
+# TYPE oracle_rac_gc_block_receive_time_seconds_total counter
+oracle_rac_gc_block_receive_time_seconds_total{inst_id="1",instance_name="orcl1",type="cr"} 0.5
+oracle_rac_gc_block_receive_time_seconds_total{inst_id="1",instance_name="orcl1",type="current"} 0.3
+# HELP oracle_rac_gc_blocks_received_total Global cache blocks received through the interconnect, by block type cr or current.
+# TYPE oracle_rac_gc_blocks_received_total counter
+oracle_rac_gc_blocks_received_total{inst_id="1",instance_name="orcl1",type="cr"} 1000
+oracle_rac_gc_blocks_received_total{inst_id="1",instance_name="orcl1",type="current"} 400
+# HELP oracle_rac_gc_wait_time_seconds_total Time waited on the global cache events of the interconnect.
+# TYPE oracle_rac_gc_wait_time_seconds_total counter
+oracle_rac_gc_wait_time_seconds_total{event="gc cr block 2-way",inst_id="2",instance_name="orcl2"} 0.25
+# HELP oracle_rac_gc_waits_total Waits of the global cache events of the interconnect.
+# TYPE oracle_rac_gc_waits_total counter
+oracle_rac_gc_waits_total{event="gc cr block 2-way",inst_id="2",instance_name="orcl2"} 500
+# HELP oracle_rac_instance_status Status of the instances of the cluster in gv$instance, 1 if OPEN and ACTIVE.
+# TYPE oracle_rac_instance_status gauge
+oracle_rac_instance_status{database_status="ACTIVE",host_name="db1",inst_id="1",instance_name="orcl1",status="OPEN"} 1
+oracle_rac_instance_status{database_status="ACTIVE",host_name="db2",inst_id="2",instance_name="orcl2",status="MOUNTED"} 0
+# HELP oracle_rac_instance_uptime_seconds Time since the startup of the instances of the cluster.
+# TYPE oracle_rac_instance_uptime_seconds gauge
+oracle_rac_instance_uptime_seconds{inst_id="1",instance_name="orcl1"} 86400
+oracle_rac_instance_uptime_seconds{inst_id="2",instance_name="orcl2"} 60
+`)
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// The tests of concurrent scrapes are meant to be run with go test -race.
+
+func TestConcurrentSnapshotScrapes(t *testing.T) {
+	snapshotCols := []string{"dbid", "snap_startup_time", "begin_interval_time", "end_interval_time", "snap_id", "instance_number"}
+	q := dbutil.NewFakeQuerier().
+		Add(`from dba_hist_snapshot s, v\$instance`, snapshotCols,
+			dbutil.Row{"1234", "2022-09-01 08:00:00", "2022-09-11 09:00:00", "2022-09-11 09:30:00", "101", "1"},
+			dbutil.Row{"1234", "2022-09-01 08:00:00", "2022-09-11 09:30:00", "2022-09-11 10:00:00", "102", "1"}).
+		Add(`from dba_hist_snapshot s, dba_hist_sqlstat`, snapshotSqlAllCols,
+			dbutil.Row{"101", "2022-09-11 09:00:00", "2022-09-11 09:30:00", "8gq2bz1wm5k3d", "APP", "select 1 from dual",
+				1.0, 10.0, 0.0, 1.0, 3.0, 0.1, 0.2, 1.0, 1.0})
+	ora := &InstanceInfoAll{InstanceInfo: InstanceInfo{VersionNum: 19.0, Edition: "EE"}, PdbInfo: PdbInfo{ConName: "CDB$ROOT", ConId: "1"}}
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scraper := withState(&ScrapeOracleSnapshot{}, NewScrapeState(NewFileStateStore(dir), "db1", time.Hour))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	total := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			metrics, err := collectMetrics(func(ch chan<- prometheus.Metric) error {
+				return scraper.Scrape(context.Background(), q, ch, ora)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			total += len(metrics)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// each snapshot is scraped once, the fake returns one row for each
+	scraped := 0
+	for _, query := range q.Queries() {
+		if strings.Contains(query, "dba_hist_sqlstat") {
+			scraped++
+		}
+	}
+	if scraped != 2 || total != 2 {
+		t.Fatalf("snapshots scraped %d times with %d metrics, want 2 and 2", scraped, total)
+	}
+}
+
+// TestConcurrentScrapes scrapes the recorded CDB and its PDB from concurrent requests
+// with different collectors, so that the collections are not shared.
+func TestConcurrentScrapes(t *testing.T) {
+	base := filepath.Join("testdata", "19c_cdb")
+	cfg, err := LoadConfig(base + ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := dbutil.LoadFixtures(base + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbutil.Replay(f)
+	defer dbutil.Replay(nil)
+
+	target := NewTarget("", cfg.OracleConfig)
+	defer target.Close()
+
+	// the snapshot queries are not recorded and fail, the state is used all the same
+	scrapers := append([]Scraper{&ScrapeOracleSnapshot{}}, goldenScrapers...)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(New(context.Background(), scrapers[:len(scrapers)-i], target, cfg))
+			if _, err := registry.Gather(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -16,9 +16,100 @@ type Scraper interface {
 	// Example: "Collect from SHOW ENGINE INNODB STATUS"
 	Help() string
 
-	// Version of MySQL from which scraper is available.
+	// Version of Oracle from which scraper is available.
 	Version() float64
 
 	// Scrape collects data from database connection and sends it over channel as prometheus metric.
-	Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error
+	Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error
+}
+
+// Gated is implemented by scrapers which only work on some kind of databases.
+type Gated interface {
+	Requirement() Requirement
+}
+
+// where a scraper runs
+const (
+	ContainerAny = ""
+	// ContainerRoot is the CDB root or a non-CDB
+	ContainerRoot = "root"
+	ContainerPdb  = "pdb"
+)
+
+// reasons of skipping a scraper
+const (
+	skipVersion      = "version"
+	skipEdition      = "edition"
+	skipContainer    = "container"
+	skipDatabaseRole = "database_role"
+	skipOpenMode     = "open_mode"
+	skipRac          = "rac"
+)
+
+// open modes in which the data dictionary can be queried
+var openedModes = []string{"READ WRITE", "READ ONLY", "READ ONLY WITH APPLY"}
+
+// openModeMounted is the open mode of a mounted standby, where only the fixed v$ views can be queried
+const openModeMounted = "MOUNTED"
+
+// Requirement describes the databases a scraper works on, empty fields match any database.
+type Requirement struct {
+	// MaxVersion is the last Oracle version the scraper is available
+	MaxVersion float64
+	// Editions as in InstanceInfo.Edition, e.g. EE
+	Editions []string
+	// Container is one of ContainerAny, ContainerRoot or ContainerPdb
+	Container string
+	// DatabaseRoles as in v$database.database_role, e.g. PRIMARY
+	DatabaseRoles []string
+	// OpenModes as in v$database.open_mode, e.g. READ WRITE
+	OpenModes []string
+	// Mounted is set if the scraper only queries fixed v$ views, which work on a MOUNTED database
+	Mounted bool
+	// Rac is set if the scraper only works in RAC mode
+	Rac bool
+}
+
+// skipReason returns why the scraper does not apply to the database, or "" if it does.
+func skipReason(s Scraper, ora *InstanceInfoAll) string {
+	if ora.VersionNum < s.Version() {
+		return skipVersion
+	}
+
+	var req Requirement
+	if g, ok := s.(Gated); ok {
+		req = g.Requirement()
+	}
+
+	if req.MaxVersion > 0 && ora.VersionNum > req.MaxVersion {
+		return skipVersion
+	}
+	if len(req.Editions) > 0 && !contains(req.Editions, ora.Edition) {
+		return skipEdition
+	}
+	if (req.Container == ContainerRoot && ora.PdbFlag) || (req.Container == ContainerPdb && !ora.PdbFlag) {
+		return skipContainer
+	}
+	if len(req.DatabaseRoles) > 0 && !contains(req.DatabaseRoles, ora.DatabaseRole) {
+		return skipDatabaseRole
+	}
+	if len(req.OpenModes) > 0 && !contains(req.OpenModes, ora.OpenMode) {
+		return skipOpenMode
+	}
+	if ora.OpenMode == openModeMounted && !req.Mounted {
+		return skipOpenMode
+	}
+	if req.Rac && !ora.Rac {
+		return skipRac
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// scraperCollector runs a scraper against a querier on each collect.
+type scraperCollector struct {
+	scraper Scraper
+	dbcli   dbutil.Querier
+	ora     *InstanceInfoAll
+	err     error
+}
+
+func (c *scraperCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *scraperCollector) Collect(ch chan<- prometheus.Metric) {
+	c.err = c.scraper.Scrape(context.Background(), c.dbcli, ch, c.ora)
+}
+
+// testScrape compares the metrics of the scraper with the expected text exposition format.
+func testScrape(t *testing.T, scraper Scraper, dbcli dbutil.Querier, ora *InstanceInfoAll, expected string) {
+	t.Helper()
+	c := &scraperCollector{scraper: scraper, dbcli: dbcli, ora: ora}
+	err := testutil.CollectAndCompare(c, strings.NewReader(expected))
+	if c.err != nil {
+		t.Fatalf("%s: scrape: %s", scraper.Name(), c.err)
+	}
+	if err != nil {
+		t.Fatalf("%s: %s", scraper.Name(), err)
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	ee19Primary := &InstanceInfoAll{
+		InstanceInfo: InstanceInfo{VersionNum: 19.0, Edition: "EE"},
+		DbInfo:       DbInfo{DatabaseRole: "PRIMARY", OpenMode: "READ WRITE"},
+	}
+	se11Standby := &InstanceInfoAll{
+		InstanceInfo: InstanceInfo{VersionNum: 11.2, Edition: "SE"},
+		DbInfo:       DbInfo{DatabaseRole: "PHYSICAL STANDBY", OpenMode: "MOUNTED"},
+	}
+	ee19Pdb := &InstanceInfoAll{
+		InstanceInfo: InstanceInfo{VersionNum: 19.0, Edition: "EE"},
+		DbInfo:       DbInfo{DatabaseRole: "PRIMARY", OpenMode: "READ WRITE"},
+		PdbFlag:      true,
+	}
+	ee19Rac := &InstanceInfoAll{
+		InstanceInfo: InstanceInfo{VersionNum: 19.0, Edition: "EE", ClusterDatabase: true},
+		DbInfo:       DbInfo{DatabaseRole: "PRIMARY", OpenMode: "READ WRITE"},
+		Rac:          true,
+	}
+
+	cases := []struct {
+		scraper Scraper
+		ora     *InstanceInfoAll
+		reason  string
+	}{
+		{ScrapeOracleStat{}, ee19Primary, ""},
+		{ScrapeOracleStat{}, se11Standby, ""},
+		{&ScrapeOracleSnapshot{}, ee19Primary, ""},
+		{&ScrapeOracleSnapshot{}, se11Standby, skipEdition},
+		{&ScrapeOracleSnapshot{}, ee19Pdb, skipContainer},
+		{ScrapeOracleTablespaceStat{}, se11Standby, skipOpenMode},
+		{ScrapeOracleParameter{}, se11Standby, ""},
+		{ScrapeCustomMetric{Metric: &CustomMetric{}}, se11Standby, skipOpenMode},
+		{ScrapeCustomMetric{Metric: &CustomMetric{Mounted: true}}, se11Standby, ""},
+		{ScrapeCustomMetric{Metric: &CustomMetric{MinVersion: 12.1}}, se11Standby, skipVersion},
+		{ScrapeCustomMetric{Metric: &CustomMetric{MinVersion: 12.1, Scope: scopePdb}}, ee19Primary, skipContainer},
+		{ScrapeCustomMetric{Metric: &CustomMetric{MinVersion: 12.1, Scope: scopePdb}}, ee19Pdb, ""},
+		{ScrapeOracleRac{}, ee19Primary, skipRac},
+		{ScrapeOracleRac{}, ee19Rac, ""},
+	}
+
+	for _, c := range cases {
+		if reason := skipReason(c.scraper, c.ora); reason != c.reason {
+			t.Fatalf("%s on %+v: got reason %q, want %q", c.scraper.Name(), c.ora, reason, c.reason)
+		}
+	}
+}
+
+func TestParseEdition(t *testing.T) {
+	cases := map[string]string{
+		"Oracle Database 11g Enterprise Edition Release 11.2.0.4.0 - 64bit Production": "EE",
+		"Oracle Database 11g Release 11.2.0.4.0 - 64bit Production":                    "SE",
+		"Oracle Database 19c Standard Edition 2 Release 19.0.0.0.0 - Production":       "SE",
+		"Oracle Database 21c Express Edition Release 21.0.0.0.0 - Production":          "XE",
+		"Oracle Database 12c Enterprise Edition Release 12.2.0.1.0 - 64bit Production": "EE",
+	}
+	for banner, edition := range cases {
+		if got := parseEdition(banner); got != edition {
+			t.Fatalf("%s: got %s, want %s", banner, got, edition)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package collector
 
 import (
 	"context"
-	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
@@ -29,10 +28,9 @@ var (
 		snapshotSqlAllCols, nil)
 )
 
-type ScrapeOracleSnapshot struct {
-	lastSnapshotId float64
-	lastScrapeTime time.Time
-}
+// ScrapeOracleSnapshot scrapes the SQL statistics of each AWR snapshot once, the scraped snapshots are kept
+// in the ScrapeState of the target.
+type ScrapeOracleSnapshot struct{}
 
 type snapshot struct {
 	dbid           string
@@ -56,45 +54,36 @@ func (*ScrapeOracleSnapshot) Version() float64 {
 	return 10.2
 }
 
-func (s *ScrapeOracleSnapshot) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
-	if ora.PdbFlag {
-		return nil
-	}
-	duration := time.Since(s.lastScrapeTime)
-	if duration < ScrapeIntervalSnapshot {
-		log.WithFields(log.Fields{"last_scrape_time": s.lastScrapeTime, "scrape_interval": ScrapeIntervalSnapshot}).Info("skip scape")
-		return nil
-	}
-
-	err := s.scrape(ctx, dbcli, ch)
-	if err != nil {
-		return err
+// AWR needs the diagnostics pack, a standby shows the snapshots of the primary
+func (*ScrapeOracleSnapshot) Requirement() Requirement {
+	return Requirement{
+		Editions:      []string{"EE"},
+		Container:     ContainerRoot,
+		DatabaseRoles: []string{"PRIMARY"},
+		OpenModes:     openedModes,
 	}
-
-	s.lastScrapeTime = time.Now()
-	return nil
 }
 
-func (s *ScrapeOracleSnapshot) scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric) error {
+// Scrape scrapes the snapshots of the last hours with an empty state, which is not saved.
+func (s *ScrapeOracleSnapshot) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	return s.ScrapeWithState(ctx, dbcli, ch, ora, NewScrapeState(nil, "", 0))
+}
 
-	// get snapshots list in last n hours, with snapshot id > last processed snapshot id
-	// process each snapshot in order
-	// record latest processed snapshot
-	stats, err := loadContext()
-	if err != nil {
-		log.WithFields(log.Fields{"error": err}).Warning("can not read local stat file")
-	}
+func (s *ScrapeOracleSnapshot) ScrapeWithState(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll, state *ScrapeState) error {
 
-	snapshots, err := getSnapshots(ctx, dbcli)
+	// get snapshots list in last n hours
+	// process each snapshot in order, which is not processed or being processed by a concurrent scrape
+	// record processed snapshots
+	name := s.Name()
+	out := newMetricSender(ch)
+	snapshots, err := getSnapshots(ctx, dbcli, out)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("get snapshot has error")
 		return err
 	}
 
-	log.WithFields(log.Fields{"snapshot_id": s.lastSnapshotId}).Info("Cached snapshot Id")
-
 	for _, s := range snapshots {
-		if s.processed(stats) {
+		if !state.claim(name, s.key()) {
 			log.WithFields(log.Fields{
 				"dbid":           s.dbid,
 				"instanceNumber": s.instanceNumber,
@@ -103,33 +92,32 @@ func (s *ScrapeOracleSnapshot) scrape(ctx context.Context, dbcli *dbutil.OracleC
 			continue
 		}
 
-		err := s.scrapeOne(ctx, dbcli, ch)
+		err := s.scrapeOne(ctx, dbcli, out)
+		state.done(name, s.key(), err == nil)
 		if err != nil {
 			return err
 		}
-
-		s.markProcessed(stats)
-		saveContext(stats)
 	}
 
-	return nil
+	return out.err()
 }
 
-func getSqlstat(ctx context.Context, dbcli *dbutil.OracleClient, dbid string, instanceNumber string, snapid string) {
+func getSqlstat(ctx context.Context, dbcli dbutil.Querier, dbid string, instanceNumber string, snapid string) {
 
 }
 
-func getSnapshots(ctx context.Context, dbcli *dbutil.OracleClient) ([]*snapshot, error) {
-	sql := `SELECT to_char(dbid),
+// getSnapshots returns the snapshots of the last hours, rows which can not be read are skipped by out.
+func getSnapshots(ctx context.Context, dbcli dbutil.Querier, out *metricSender) ([]*snapshot, error) {
+	sql := `SELECT to_char(dbid) as dbid,
    to_char(sys_extract_utc(s.startup_time), 'yyyy-mm-dd hh24:mi:ss') snap_startup_time,
    to_char(sys_extract_utc(s.begin_interval_time), 'yyyy-mm-dd hh24:mi:ss') begin_interval_time,
    to_char(sys_extract_utc(s.end_interval_time), 'yyyy-mm-dd hh24:mi:ss') end_interval_time,
-   to_char(s.snap_id), 
-   to_char(s.instance_number)
+   to_char(s.snap_id) as snap_id, 
+   to_char(s.instance_number) as instance_number
 from dba_hist_snapshot  s, v$instance b
 where s.end_interval_time >= sysdate - interval '2' hour
 and s.INSTANCE_NUMBER = b.INSTANCE_NUMBER`
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
@@ -137,12 +125,16 @@ and s.INSTANCE_NUMBER = b.INSTANCE_NUMBER`
 	var ret []*snapshot
 
 	for _, r := range rows {
-		s := snapshot{dbid: r[0].(string),
-			startupTime:    r[1].(string),
-			beginTime:      r[2].(string),
-			endTime:        r[3].(string),
-			snapId:         r[4].(string),
-			instanceNumber: r[5].(string)}
+		s := snapshot{dbid: r.String("dbid"),
+			startupTime:    r.String("snap_startup_time"),
+			beginTime:      r.String("begin_interval_time"),
+			endTime:        r.String("end_interval_time"),
+			snapId:         r.String("snap_id"),
+			instanceNumber: r.String("instance_number")}
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 
 		ret = append(ret, &s)
 	}
@@ -150,35 +142,26 @@ and s.INSTANCE_NUMBER = b.INSTANCE_NUMBER`
 
 }
 
-func (s *snapshot) processed(cache map[string]string) bool {
-	key := s.dbid + "-" + s.instanceNumber + "-" + s.snapId
-	if _, ok := cache[key]; ok {
-		return true
-	}
-
-	return false
-}
-
-func (s *snapshot) markProcessed(cache map[string]string) {
-	key := s.dbid + "-" + s.instanceNumber + "-" + s.snapId
-	cache[key] = "Yes"
+// key identifies the snapshot in the ScrapeState.
+func (s *snapshot) key() string {
+	return s.dbid + "-" + s.instanceNumber + "-" + s.snapId
 }
 
-func (s *snapshot) scrapeOne(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric) error {
-	sql := `select to_char(s.snap_id), 
-    to_char(s.begin_interval_time, 'yyyy-mm-dd hh24:mi:ss'), 
-    to_char(s.end_interval_time, 'yyyy-mm-dd hh24:mi:ss'),
+func (s *snapshot) scrapeOne(ctx context.Context, dbcli dbutil.Querier, out *metricSender) error {
+	sql := `select to_char(s.snap_id) as snap_id, 
+    to_char(s.begin_interval_time, 'yyyy-mm-dd hh24:mi:ss') as begin_time, 
+    to_char(s.end_interval_time, 'yyyy-mm-dd hh24:mi:ss') as end_time,
     t.sql_id, 
-    parsing_schema_name,
-    to_char(substr(x.sql_text,1,4000)),
-    t.version_count, t.executions_delta,
-    round(sorts_delta/(decode(executions_delta,0,1,executions_delta)), 4),
-    round(disk_reads_delta/(decode(executions_delta,0,1,executions_delta)), 4),
-    round(buffer_gets_delta/(decode(executions_delta,0,1,executions_delta)), 4),
-    round(cpu_time_delta/(decode(executions_delta,0,1,executions_delta))/1000, 4),
-    round(elapsed_time_delta/(decode(executions_delta,0,1,executions_delta))/1000, 4),
-    round(parse_calls_delta/(decode(executions_delta,0,1,executions_delta)), 4),
-    round(rows_processed_delta/(decode(executions_delta,0,1,executions_delta)), 2)
+    parsing_schema_name as parsing_schema,
+    to_char(substr(x.sql_text,1,4000)) as sql_text,
+    t.version_count, t.executions_delta as executions,
+    round(sorts_delta/(decode(executions_delta,0,1,executions_delta)), 4) as sorts,
+    round(disk_reads_delta/(decode(executions_delta,0,1,executions_delta)), 4) as disk_reads,
+    round(buffer_gets_delta/(decode(executions_delta,0,1,executions_delta)), 4) as buffer_gets,
+    round(cpu_time_delta/(decode(executions_delta,0,1,executions_delta))/1000, 4) as cpu_time,
+    round(elapsed_time_delta/(decode(executions_delta,0,1,executions_delta))/1000, 4) as elapsed_time,
+    round(parse_calls_delta/(decode(executions_delta,0,1,executions_delta)), 4) as parse_calls,
+    round(rows_processed_delta/(decode(executions_delta,0,1,executions_delta)), 2) as rows_processed
 from dba_hist_snapshot s, dba_hist_sqlstat t, dba_hist_sqltext x
  where s.dbid = :1
    and s.snap_id = :2
@@ -191,24 +174,24 @@ from dba_hist_snapshot s, dba_hist_sqlstat t, dba_hist_sqltext x
    and (t.buffer_gets_delta > 0 or t.executions_delta > 0)
 `
 	params := []interface{}{s.dbid, s.snapId, s.instanceNumber}
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql, params...)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql, params...)
 	if err != nil {
 		return err
 	}
 
 	for _, r := range rows {
-		ch <- prometheus.MustNewConstMetric(oracleSnapshotSqlStatAllDesc, prometheus.GaugeValue, 1,
-			r[0].(string), r[1].(string), r[2].(string), r[3].(string), r[4].(string), r[5].(string),
-			formatFloat64(r[6].(float64)),
-			formatFloat64(r[7].(float64)),
-			formatFloat64(r[8].(float64)),
-			formatFloat64(r[9].(float64)),
-			formatFloat64(r[10].(float64)),
-			formatFloat64(r[11].(float64)),
-			formatFloat64(r[12].(float64)),
-			formatFloat64(r[13].(float64)),
-			formatFloat64(r[14].(float64)),
-		)
+		var labels []string
+		for _, col := range snapshotSqlLabelCols {
+			labels = append(labels, r.String(col))
+		}
+		for _, col := range snapshotSqlAllCols[len(snapshotSqlLabelCols):] {
+			labels = append(labels, formatFloat64(r.Float(col)))
+		}
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		out.send(oracleSnapshotSqlStatAllDesc, prometheus.GaugeValue, 1, labels...)
 	}
 
 	return nil
@@ -29,22 +29,31 @@ func (ScrapeOracleSqlStat) Version() float64 {
 	return 10.2
 }
 
-func (ScrapeOracleSqlStat) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+func (ScrapeOracleSqlStat) Requirement() Requirement {
+	return Requirement{Mounted: true}
+}
+
+func (ScrapeOracleSqlStat) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 
 	sql := `select * from (select sql_id, sql_text, executions, fetches, sorts, buffer_gets, rows_processed 
 from v$sql order by buffer_gets desc ) 
 where rownum < 1`
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return err
 	}
+	out := newMetricSender(ch)
 	for _, r := range rows {
-		ch <- prometheus.MustNewConstMetric(
-			oracleSqlStatDesc, prometheus.GaugeValue, 1, r[0].(string),
-			r[1].(string), formatFloat64(r[2].(float64)), formatFloat64(r[3].(float64)),
-			formatFloat64(r[4].(float64)), formatFloat64(r[5].(float64)), formatFloat64(r[6].(float64)))
+		labels := []string{r.String("sql_id"),
+			r.String("sql_text"), r.String("executions"), r.String("fetches"),
+			r.String("sorts"), r.String("buffer_gets"), r.String("rows_processed")}
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		out.send(oracleSqlStatDesc, prometheus.GaugeValue, 1, labels...)
 
 	}
-	return nil
+	return out.err()
 }
@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// Stateful is implemented by scrapers which keep state across scrapes, e.g. the AWR snapshots already scraped.
+// The exporter scrapes them with the state of the target instead of calling Scrape, which runs with an empty state.
+type Stateful interface {
+	ScrapeWithState(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll, state *ScrapeState) error
+}
+
+// ScrapeState is the state of the stateful scrapers of a target: the keys each collector processed.
+// It is shared by the concurrent scrapes of the target and its PDBs, and by concurrent requests,
+// all access is serialized by its mutex. The keys of a collector are loaded from the store on first use,
+// keys older than the ttl are pruned.
+type ScrapeState struct {
+	mu sync.Mutex
+	// store is nil for a state kept in memory
+	store  StateStore
+	target string
+	ttl    time.Duration
+	sets   map[string]*stateSet
+}
+
+// stateSet are the keys of a collector, processed are the ones done, inFlight the ones being processed.
+type stateSet struct {
+	processed map[string]time.Time
+	inFlight  map[string]bool
+	dirty     bool
+}
+
+// NewScrapeState returns the state of the target saved in store, a nil store keeps the state in memory.
+// A ttl of 0 keeps the keys forever.
+func NewScrapeState(store StateStore, target string, ttl time.Duration) *ScrapeState {
+	return &ScrapeState{store: store, target: target, ttl: ttl, sets: make(map[string]*stateSet)}
+}
+
+// defaultStateKey is the key of the default target, named "", in the store.
+const defaultStateKey = "default"
+
+// namespace separates the keys of the targets and collectors in the store.
+func (s *ScrapeState) namespace(collector string) string {
+	target := s.target
+	if target == "" {
+		target = defaultStateKey
+	}
+	return target + "/" + collector
+}
+
+func (s *ScrapeState) set(collector string) *stateSet {
+	set, ok := s.sets[collector]
+	if ok {
+		return set
+	}
+
+	set = &stateSet{processed: make(map[string]time.Time), inFlight: make(map[string]bool)}
+	s.sets[collector] = set
+	if s.store == nil {
+		return set
+	}
+	processed, err := s.store.Load(s.namespace(collector))
+	if err != nil {
+		// the keys are processed again
+		log.WithFields(log.Fields{"target": s.target, "collector": collector, "error": err}).Warn("Load Scrape State Failed")
+		return set
+	}
+	set.processed = processed
+	set.dirty = s.prune(set)
+	return set
+}
+
+// prune removes the keys older than the ttl, and returns whether any was removed.
+func (s *ScrapeState) prune(set *stateSet) bool {
+	if s.ttl <= 0 {
+		return false
+	}
+	pruned := false
+	for key, at := range set.processed {
+		if time.Since(at) > s.ttl {
+			delete(set.processed, key)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// claim returns true if the key of the collector is neither processed nor being processed, and marks it
+// as being processed, so that a key is processed once by concurrent scrapes. The claim is ended by done.
+func (s *ScrapeState) claim(collector string, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := s.set(collector)
+	if _, ok := set.processed[key]; ok || set.inFlight[key] {
+		return false
+	}
+	set.inFlight[key] = true
+	return true
+}
+
+// done ends the claim of the key, a processed key is saved by the next flush, a failed one can be claimed again.
+func (s *ScrapeState) done(collector string, key string, processed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := s.set(collector)
+	delete(set.inFlight, key)
+	if processed {
+		set.processed[key] = time.Now()
+		set.dirty = true
+	}
+}
+
+// flush prunes and saves the changed keys of the collector.
+func (s *ScrapeState) flush(collector string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set, ok := s.sets[collector]
+	if !ok || s.store == nil {
+		return nil
+	}
+	if s.prune(set) {
+		set.dirty = true
+	}
+	if !set.dirty {
+		return nil
+	}
+
+	err := s.store.Save(s.namespace(collector), set.processed)
+	if err != nil {
+		return err
+	}
+	set.dirty = false
+	return nil
+}
+
+// statefulScraper scrapes a Stateful scraper with the state of a target, and saves the state after each scrape.
+type statefulScraper struct {
+	Scraper
+	state *ScrapeState
+}
+
+func (s statefulScraper) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+	err := s.Scraper.(Stateful).ScrapeWithState(ctx, dbcli, ch, ora, s.state)
+	flushErr := s.state.flush(s.Name())
+	if flushErr != nil {
+		log.WithFields(log.Fields{"target": s.state.target, "collector": s.Name(), "error": flushErr}).Error("Save Scrape State Failed")
+		if err == nil {
+			err = flushErr
+		}
+	}
+	return err
+}
+
+// withState returns the scraper bound to state if it is Stateful.
+func withState(scraper Scraper, state *ScrapeState) Scraper {
+	if _, ok := scraper.(Stateful); ok {
+		return statefulScraper{Scraper: scraper, state: state}
+	}
+	return scraper
+}
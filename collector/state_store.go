@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StateStore persists the keys processed by the stateful scrapers, e.g. the AWR snapshots already scraped,
+// with the time they were processed. The namespace separates the targets and collectors.
+type StateStore interface {
+	// Load returns the entries of the namespace, none if it was never saved.
+	Load(namespace string) (map[string]time.Time, error)
+	// Save replaces the entries of the namespace.
+	Save(namespace string, entries map[string]time.Time) error
+}
+
+// FileStateStore keeps each namespace in a JSON file of a directory. A file is replaced atomically
+// by writing and syncing a temporary file which is renamed. The saves of a file are serialized,
+// e.g. a replaced target still flushing while its successor scrapes.
+type FileStateStore struct {
+	dir   string
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+type stateFile struct {
+	Entries map[string]time.Time `json:"entries"`
+}
+
+func NewFileStateStore(dir string) *FileStateStore {
+	return &FileStateStore{dir: dir, locks: make(map[string]*sync.Mutex)}
+}
+
+// lock locks the file of the namespace until the returned func is called.
+func (s *FileStateStore) lock(namespace string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[namespace]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[namespace] = l
+	}
+	s.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// file returns the file of the namespace, which is escaped to a single file name.
+func (s *FileStateStore) file(namespace string) string {
+	return filepath.Join(s.dir, url.PathEscape(namespace)+".json")
+}
+
+func (s *FileStateStore) Load(namespace string) (map[string]time.Time, error) {
+	buf, err := ioutil.ReadFile(s.file(namespace))
+	if os.IsNotExist(err) {
+		return map[string]time.Time{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := stateFile{}
+	err = json.Unmarshal(buf, &f)
+	if err != nil {
+		return nil, fmt.Errorf("parse state %s: %s", s.file(namespace), err)
+	}
+	if f.Entries == nil {
+		f.Entries = map[string]time.Time{}
+	}
+	return f.Entries, nil
+}
+
+func (s *FileStateStore) Save(namespace string, entries map[string]time.Time) error {
+	buf, err := json.MarshalIndent(stateFile{Entries: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	defer s.lock(namespace)()
+	err = os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	file := s.file(namespace)
+	tmp, err := ioutil.TempFile(s.dir, filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(buf, '\n'))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = os.Rename(tmp.Name(), file)
+	if err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// syncDir makes a rename in the directory durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
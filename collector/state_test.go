@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileStateStore(filepath.Join(dir, "state"))
+
+	entries, err := store.Load("db1/oracle_sql_snapshot")
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("load of a new namespace: %v %v", entries, err)
+	}
+
+	at := time.Date(2022, 9, 11, 10, 0, 0, 0, time.UTC)
+	if err := store.Save("db1/oracle_sql_snapshot", map[string]time.Time{"1234-1-101": at}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("/oracle_sql_snapshot", map[string]time.Time{"1234-1-102": at}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = store.Load("db1/oracle_sql_snapshot")
+	if err != nil || len(entries) != 1 || !entries["1234-1-101"].Equal(at) {
+		t.Fatalf("loaded %v %v", entries, err)
+	}
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "state"))
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if len(names) != 2 || names[0] != "%2Foracle_sql_snapshot.json" || names[1] != "db1%2Foracle_sql_snapshot.json" {
+		t.Fatalf("state files %v, want one per namespace without temporary files", names)
+	}
+}
+
+func TestScrapeStateTTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileStateStore(dir)
+	err = store.Save("db1/oracle_sql_snapshot", map[string]time.Time{
+		"1234-1-100": time.Now().Add(-2 * time.Hour),
+		"1234-1-101": time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScrapeState(store, "db1", time.Hour)
+	if s.claim("oracle_sql_snapshot", "1234-1-101") {
+		t.Fatal("processed key claimed")
+	}
+	if !s.claim("oracle_sql_snapshot", "1234-1-100") {
+		t.Fatal("expired key not claimed")
+	}
+	if s.claim("oracle_sql_snapshot", "1234-1-100") {
+		t.Fatal("key claimed twice")
+	}
+	s.done("oracle_sql_snapshot", "1234-1-100", false)
+	if !s.claim("oracle_sql_snapshot", "1234-1-100") {
+		t.Fatal("failed key not claimed again")
+	}
+	s.done("oracle_sql_snapshot", "1234-1-100", true)
+	if err := s.flush("oracle_sql_snapshot"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new state of the target, e.g. after a restart, loads the keys
+	s = NewScrapeState(store, "db1", time.Hour)
+	if s.claim("oracle_sql_snapshot", "1234-1-100") {
+		t.Fatal("saved key claimed after reload")
+	}
+	if !NewScrapeState(store, "db2", time.Hour).claim("oracle_sql_snapshot", "1234-1-100") {
+		t.Fatal("key of another target not claimed")
+	}
+}
+
+func TestScrapeStateDefaultTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewScrapeState(NewFileStateStore(dir), "", time.Hour)
+	s.claim("oracle_sql_snapshot", "1234-1-101")
+	s.done("oracle_sql_snapshot", "1234-1-101", true)
+	if err := s.flush("oracle_sql_snapshot"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "default%2Foracle_sql_snapshot.json")); err != nil {
+		t.Fatalf("state of the default target: %v", err)
+	}
+}
+
+func TestFileStateStoreSerializesSaves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileStateStore(dir)
+
+	// a save waits for the one in progress on the same file, not for the other files
+	unlock := store.lock("db1/oracle_sql_snapshot")
+	if err := store.Save("db2/oracle_sql_snapshot", map[string]time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	saved := make(chan error)
+	go func() {
+		saved <- store.Save("db1/oracle_sql_snapshot", map[string]time.Time{})
+	}()
+	select {
+	case <-saved:
+		t.Fatal("save not serialized with the one in progress")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case err := <-saved:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("save not done after the one in progress")
+	}
+}
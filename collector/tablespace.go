@@ -45,66 +45,74 @@ func (ScrapeOracleTablespaceStat) Version() float64 {
 	return 10.2
 }
 
-func (ScrapeOracleTablespaceStat) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+// dba_ views need an opened database
+func (ScrapeOracleTablespaceStat) Requirement() Requirement {
+	return Requirement{OpenModes: openedModes}
+}
+
+func (ScrapeOracleTablespaceStat) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 
-	tbsInfo, err := getTbsSpaceInfo(ctx, dbcli)
+	out := newMetricSender(ch)
+	tbsInfo, err := getTbsSpaceInfo(ctx, dbcli, out)
 	if err != nil {
 		return err
 	}
 	for _, tbs := range tbsInfo {
-		ch <- prometheus.MustNewConstMetric(
+		row := out.row()
+		row.send(
 			oracleTablespaceDesc, prometheus.GaugeValue, tbs.spaceTotal,
 			tbs.tablespaceName, tbs.contents, tbs.status, "total", ora.ConId, ora.ConName)
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleTablespaceDesc, prometheus.GaugeValue, tbs.spaceExtensible,
 			tbs.tablespaceName, tbs.contents, tbs.status, "extensible", ora.ConId, ora.ConName)
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleTablespaceDesc, prometheus.GaugeValue, tbs.spaceUsed,
 			tbs.tablespaceName, tbs.contents, tbs.status, "used", ora.ConId, ora.ConName)
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleTablespaceDesc, prometheus.GaugeValue, tbs.usedPct,
 			tbs.tablespaceName, tbs.contents, tbs.status, "used_pct", ora.ConId, ora.ConName)
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleTablespaceDesc, prometheus.GaugeValue, tbs.usedPctExtensible,
 			tbs.tablespaceName, tbs.contents, tbs.status, "used_pct_ext", ora.ConId, ora.ConName)
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleTablespaceDesc, prometheus.GaugeValue, tbs.spaceFree,
 			tbs.tablespaceName, tbs.contents, tbs.status, "free", ora.ConId, ora.ConName)
 
-		ch <- prometheus.MustNewConstMetric(
+		row.send(
 			oracleTablespaceDesc, prometheus.GaugeValue, tbs.recyclebinUsed,
 			tbs.tablespaceName, tbs.contents, tbs.status, "recyclebin_used", ora.ConId, ora.ConName)
 	}
-	return nil
+	return out.err()
 }
 
-func getTbsSpaceInfo(ctx context.Context, dbcli *dbutil.OracleClient) ([]*TablespaceInfo, error) {
-	tbsList, err := getTbsMeta(ctx, dbcli)
+// getTbsSpaceInfo returns the space of the tablespaces, rows which can not be read are skipped by out.
+func getTbsSpaceInfo(ctx context.Context, dbcli dbutil.Querier, out *metricSender) ([]*TablespaceInfo, error) {
+	tbsList, err := getTbsMeta(ctx, dbcli, out)
 	if err != nil {
 		return nil, err
 	}
 
-	tbsUsedSpace, err := getTbsUsedSpace(ctx, dbcli)
+	tbsUsedSpace, err := getTbsUsedSpace(ctx, dbcli, out)
 	if err != nil {
 		return nil, err
 	}
 
-	tbsFreeSpace, err := getTbsFreeSpace(ctx, dbcli)
+	tbsFreeSpace, err := getTbsFreeSpace(ctx, dbcli, out)
 	if err != nil {
 		return nil, err
 	}
 
-	tempUsed, err := getTempTablespaceUsed(ctx, dbcli)
+	tempUsed, err := getTempTablespaceUsed(ctx, dbcli, out)
 	if err != nil {
 		return nil, err
 	}
 
-	recyclebinUsed, err := getTbsRecyclebinUsed(ctx, dbcli)
+	recyclebinUsed, err := getTbsRecyclebinUsed(ctx, dbcli, out)
 	if err != nil {
 		return nil, err
 	}
@@ -138,9 +146,9 @@ func getTbsSpaceInfo(ctx context.Context, dbcli *dbutil.OracleClient) ([]*Tables
 	return tbsList, nil
 }
 
-func getTbsMeta(ctx context.Context, dbcli *dbutil.OracleClient) ([]*TablespaceInfo, error) {
+func getTbsMeta(ctx context.Context, dbcli dbutil.Querier, out *metricSender) ([]*TablespaceInfo, error) {
 	sql := `select tablespace_name, contents, status, block_size from dba_tablespaces`
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
@@ -148,16 +156,20 @@ func getTbsMeta(ctx context.Context, dbcli *dbutil.OracleClient) ([]*TablespaceI
 	var tbsList []*TablespaceInfo
 	for _, r := range rows {
 		tbs := TablespaceInfo{}
-		tbs.tablespaceName = r[0].(string)
-		tbs.contents = r[1].(string)
-		tbs.status = r[2].(string)
-		tbs.blockSize = r[3].(float64)
+		tbs.tablespaceName = r.String("tablespace_name")
+		tbs.contents = r.String("contents")
+		tbs.status = r.String("status")
+		tbs.blockSize = r.Float("block_size")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 		tbsList = append(tbsList, &tbs)
 	}
 	return tbsList, nil
 }
 
-func getTbsUsedSpace(ctx context.Context, dbcli *dbutil.OracleClient) (map[string][]float64, error) {
+func getTbsUsedSpace(ctx context.Context, dbcli dbutil.Querier, out *metricSender) (map[string][]float64, error) {
 	sql := `select
   tablespace_name,
   sum(BYTES) as space_total,
@@ -178,29 +190,33 @@ from DBA_TEMP_FILES
 where status = 'ONLINE'
 group by tablespace_name`
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
 	tbsUsed := make(map[string][]float64)
 	for _, r := range rows {
-		tbsName := r[0].(string)
-		spaceTotal := r[1].(float64)
-		spaceExtensible := r[2].(float64)
-		numFiles := r[3].(float64)
+		tbsName := r.String("tablespace_name")
+		spaceTotal := r.Float("space_total")
+		spaceExtensible := r.Float("space_extensible")
+		numFiles := r.Float("num_files")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 		tbsUsed[tbsName] = []float64{spaceTotal, spaceExtensible, numFiles}
 	}
 	return tbsUsed, nil
 }
 
-func getTbsFreeSpace(ctx context.Context, dbcli *dbutil.OracleClient) (map[string]float64, error) {
-	result, err := getTbsFreeSpaceNonRecyclebin(ctx, dbcli)
+func getTbsFreeSpace(ctx context.Context, dbcli dbutil.Querier, out *metricSender) (map[string]float64, error) {
+	result, err := getTbsFreeSpaceNonRecyclebin(ctx, dbcli, out)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Info("getTbsFreeSpace error")
 		errmsg := err.Error()
 		if strings.Contains(errmsg, "ORA-00942") || strings.Contains(errmsg, "ORA-01031") {
 			log.Info("No dba_free_space_nonrecyclebin, fallback to dba_free_space")
-			return getTbsFreeSpaceWithRecyclebin(ctx, dbcli)
+			return getTbsFreeSpaceWithRecyclebin(ctx, dbcli, out)
 		}
 		return nil, err
 	}
@@ -208,75 +224,91 @@ func getTbsFreeSpace(ctx context.Context, dbcli *dbutil.OracleClient) (map[strin
 	return result, err
 }
 
-func getTbsFreeSpaceWithRecyclebin(ctx context.Context, dbcli *dbutil.OracleClient) (map[string]float64, error) {
+func getTbsFreeSpaceWithRecyclebin(ctx context.Context, dbcli dbutil.Querier, out *metricSender) (map[string]float64, error) {
 	sql := `select tablespace_name, sum(bytes) as space_free
 from dba_free_space
 group by tablespace_name`
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
 
 	tbsFree := make(map[string]float64)
 	for _, r := range rows {
-		tbsName := r[0].(string)
-		spaceFree := r[1].(float64)
+		tbsName := r.String("tablespace_name")
+		spaceFree := r.Float("space_free")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 		tbsFree[tbsName] = spaceFree
 	}
 	return tbsFree, nil
 }
 
-func getTbsFreeSpaceNonRecyclebin(ctx context.Context, dbcli *dbutil.OracleClient) (map[string]float64, error) {
+func getTbsFreeSpaceNonRecyclebin(ctx context.Context, dbcli dbutil.Querier, out *metricSender) (map[string]float64, error) {
 	sql := `select tablespace_name, sum(bytes) as space_free
 from dba_free_space_nonrecyclebin
 group by tablespace_name`
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
 
 	tbsFree := make(map[string]float64)
 	for _, r := range rows {
-		tbsName := r[0].(string)
-		spaceFree := r[1].(float64)
+		tbsName := r.String("tablespace_name")
+		spaceFree := r.Float("space_free")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 		tbsFree[tbsName] = spaceFree
 	}
 	return tbsFree, nil
 }
 
-func getTbsRecyclebinUsed(ctx context.Context, dbcli *dbutil.OracleClient) (map[string]float64, error) {
-	sql := `select ts_name, sum(space) from dba_recyclebin group by ts_name`
+func getTbsRecyclebinUsed(ctx context.Context, dbcli dbutil.Querier, out *metricSender) (map[string]float64, error) {
+	sql := `select ts_name, sum(space) as space from dba_recyclebin group by ts_name`
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
 
 	recyclebin := make(map[string]float64)
 	for _, r := range rows {
-		tbsName := r[0].(string)
-		recyclebinUsed := r[1].(float64)
+		tbsName := r.String("ts_name")
+		recyclebinUsed := r.Float("space")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 		recyclebin[tbsName] = recyclebinUsed
 	}
 	return recyclebin, nil
 }
 
-func getTempTablespaceUsed(ctx context.Context, dbcli *dbutil.OracleClient) (map[string]float64, error) {
-	sql := `select tablespace_name, sum(used_blocks)
+func getTempTablespaceUsed(ctx context.Context, dbcli dbutil.Querier, out *metricSender) (map[string]float64, error) {
+	sql := `select tablespace_name, sum(used_blocks) as used_blocks
 from V$SORT_SEGMENT
 group by tablespace_name`
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return nil, err
 	}
 
 	tempSpaces := make(map[string]float64)
 	for _, r := range rows {
-		tbsName := r[0].(string)
-		blocksUsed := r[1].(float64)
+		tbsName := r.String("tablespace_name")
+		blocksUsed := r.Float("used_blocks")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 		tempSpaces[tbsName] = blocksUsed
 	}
 	return tempSpaces, nil
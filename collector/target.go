@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"reflect"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+// Target holds the state of one monitored database which lives across scrapes.
+type Target struct {
+	Name    string
+	Client  *dbutil.OracleClient
+	Metrics Metrics
+	cache   *scrapeCache
+	flights *scrapeFlights
+	state   *ScrapeState
+	// stateConfig is the config of the store of state
+	stateConfig StateConfig
+	// refs counts the requests which got the target and have not released it yet
+	refs sync.WaitGroup
+	// closed is closed when the target is closed after it was replaced
+	closed chan struct{}
+}
+
+// NewTarget returns a target whose scrape state is kept in memory.
+func NewTarget(name string, dbConfig dbutil.OracleConfig) *Target {
+	return &Target{
+		Name:    name,
+		Client:  dbutil.NewOracleClient(dbConfig),
+		Metrics: NewMetrics(),
+		cache:   newScrapeCache(),
+		flights: newScrapeFlights(),
+		state:   NewScrapeState(nil, name, 0),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (t *Target) Close() error {
+	return t.Client.Close()
+}
+
+// Release releases the target got from Targets.Get when the request is done.
+func (t *Target) Release() {
+	t.refs.Done()
+}
+
+// closeWhenIdle closes a target removed from the targets, after the requests which got it released it and
+// the collections they started are done, even if the requests timed out before their collections.
+func (t *Target) closeWhenIdle() {
+	t.refs.Wait()
+	t.flights.wait()
+	log.WithFields(log.Fields{"target": t.Name}).Info("Close Target")
+	err := t.Close()
+	if err != nil {
+		log.WithFields(log.Fields{"target": t.Name, "error": err}).Error("Close Target has error")
+	}
+	close(t.closed)
+}
+
+// Targets keeps the targets of the config file by name.
+// The default target at the top level of the config file is named "", it is left out like
+// in Config.Databases if it is empty and targets are configured.
+type Targets struct {
+	mu      sync.Mutex
+	targets map[string]*Target
+	// store is shared by the targets and their successors while the state directory is unchanged
+	store *FileStateStore
+}
+
+func NewTargets() *Targets {
+	return &Targets{targets: make(map[string]*Target)}
+}
+
+// Update syncs the targets with the config, targets whose database and state config is unchanged are kept
+// with their open connections, removed or changed targets are closed once their scrapes in flight are done.
+// The scrape state of the targets is saved in the state directory of the config.
+func (ts *Targets) Update(cfg *Config) {
+	dbConfigs := make(map[string]dbutil.OracleConfig)
+	if cfg.hasDefaultDatabase() {
+		dbConfigs[""] = cfg.OracleConfig
+	}
+	for _, t := range cfg.Targets {
+		dbConfigs[t.Name] = t
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.store == nil || ts.store.dir != cfg.State.GetDir() {
+		ts.store = NewFileStateStore(cfg.State.GetDir())
+	}
+	for name, t := range ts.targets {
+		if dbConfig, ok := dbConfigs[name]; ok && reflect.DeepEqual(dbConfig, t.Client.C) && t.stateConfig == cfg.State {
+			continue
+		}
+		delete(ts.targets, name)
+		go t.closeWhenIdle()
+	}
+
+	for name, dbConfig := range dbConfigs {
+		if _, ok := ts.targets[name]; !ok {
+			t := NewTarget(name, dbConfig)
+			t.state = NewScrapeState(ts.store, name, cfg.State.GetTTL())
+			t.stateConfig = cfg.State
+			ts.targets[name] = t
+		}
+	}
+}
+
+// Get returns the named target, which must be released when the request is done, so that it is not
+// closed while in use if the config is reloaded.
+func (ts *Targets) Get(name string) (*Target, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	t, ok := ts.targets[name]
+	if ok {
+		t.refs.Add(1)
+	}
+	return t, ok
+}
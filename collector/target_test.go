@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+func TestTargetsUpdateClosesWhenIdle(t *testing.T) {
+	cfg := &Config{Targets: []dbutil.OracleConfig{{Name: "db1"}}}
+	ts := NewTargets()
+	ts.Update(cfg)
+
+	target, ok := ts.Get("db1")
+	if !ok {
+		t.Fatal("target db1 not found")
+	}
+
+	// the request times out before its collection, which keeps running
+	release := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := target.flights.do(ctx, "oracle_stat", 0, func() ([]prometheus.Metric, bool) {
+		<-release
+		return nil, true
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got error %v", err)
+	}
+
+	ts.Update(&Config{})
+	if _, ok := ts.Get("db1"); ok {
+		t.Fatal("removed target db1 found")
+	}
+
+	target.Release()
+	select {
+	case <-target.closed:
+		t.Fatal("target closed with a collection in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-target.closed:
+	case <-time.After(time.Second):
+		t.Fatal("target not closed after its collection")
+	}
+}
+
+func TestTargetsUpdateDefault(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  *Config
+		want bool
+	}{
+		{"no targets", &Config{}, true},
+		{"empty top level with targets", &Config{Targets: []dbutil.OracleConfig{{Name: "db1"}}}, false},
+		{"top level with targets", &Config{
+			OracleConfig: dbutil.OracleConfig{Host: "localhost"},
+			Targets:      []dbutil.OracleConfig{{Name: "db1"}},
+		}, true},
+	}
+	for _, c := range cases {
+		ts := NewTargets()
+		ts.Update(c.cfg)
+		target, ok := ts.Get("")
+		if ok {
+			target.Release()
+		}
+		if ok != c.want {
+			t.Errorf("%s: default target found %v, want %v", c.name, ok, c.want)
+		}
+		if len(ts.targets) != len(c.cfg.Databases()) {
+			t.Errorf("%s: %d targets, want the %d databases of the config", c.name, len(ts.targets), len(c.cfg.Databases()))
+		}
+	}
+}
+
+func TestTargetsUpdateSharesStore(t *testing.T) {
+	ts := NewTargets()
+	ts.Update(&Config{Targets: []dbutil.OracleConfig{{Name: "db1"}}})
+	old, _ := ts.Get("db1")
+	old.Release()
+
+	// the replaced target saves its state with the same store as its successor
+	ts.Update(&Config{Targets: []dbutil.OracleConfig{{Name: "db1", Host: "localhost"}}})
+	target, _ := ts.Get("db1")
+	target.Release()
+	if target == old || target.state.store != old.state.store {
+		t.Fatal("replaced target and its successor do not share the state store")
+	}
+}
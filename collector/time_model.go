@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"fmt"
 	// "database/sql"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -11,25 +12,25 @@ import (
 )
 
 var (
-	oracleDbTimeDesc = prometheus.NewDesc(
+	oracleDbTimeDesc = newInstanceDesc(
 		prometheus.BuildFQName(namespace, "time_model", "db_time"),
 		"Oracle TIme Model",
-		[]string{"con_id", "con_name"}, nil)
+		[]string{"con_id", "con_name"})
 
-	oracleDbCpuDesc = prometheus.NewDesc(
+	oracleDbCpuDesc = newInstanceDesc(
 		prometheus.BuildFQName(namespace, "time_model", "db_cpu"),
 		"Oracle Time Model",
-		[]string{"con_id", "con_name"}, nil)
+		[]string{"con_id", "con_name"})
 
-	oracleBackgroundCpuDesc = prometheus.NewDesc(
+	oracleBackgroundCpuDesc = newInstanceDesc(
 		prometheus.BuildFQName(namespace, "time_model", "background_cpu"),
 		"Oracle Time Model",
-		[]string{"con_id", "con_name"}, nil)
+		[]string{"con_id", "con_name"})
 
-	oracleTimeModelDesc = prometheus.NewDesc(
+	oracleTimeModelDesc = newInstanceDesc(
 		prometheus.BuildFQName(namespace, "time_model", "stat"),
 		"Oracle Time Model",
-		[]string{"stat_name", "con_id", "con_name"}, nil)
+		[]string{"stat_name", "con_id", "con_name"})
 )
 
 type ScrapeOracleTimeModel struct{}
@@ -47,48 +48,58 @@ func (ScrapeOracleTimeModel) Version() float64 {
 	return 10.2
 }
 
+func (ScrapeOracleTimeModel) Requirement() Requirement {
+	return Requirement{Mounted: true}
+}
+
 // db time
 // db cpu, background cpu
 // elapsetime
 
-func (ScrapeOracleTimeModel) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+func (ScrapeOracleTimeModel) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 	var sql string
 	if ora.VersionNum < 12.0 {
-		sql = `select stat_name, value, 0 as con_id
-from v$sys_time_model
+		sql = `select %sstat_name, value, 0 as con_id
+from %s
 `
 	} else {
-		sql = `select stat_name, value, con_id
-from v$sys_time_model
+		sql = `select %sstat_name, value, con_id
+from %s
 `
 	}
+	sql = fmt.Sprintf(sql, instanceColumn(ora, ""), instanceView(ora, "sys_time_model"))
 
 	//where stat_name in ('DB time', 'DB CPU', 'background cpu time')
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Error("scrape oracle time model has error")
 		return err
 	}
+	out := newMetricSender(ch)
 	for _, r := range rows {
-		stat_name := r[0].(string)
-		val := r[1].(float64)
-		conId := r[2].(float64)
+		stat_name := r.String("stat_name")
+		val := r.Float("value")
+		labels := instanceLabels(ora, r, r.String("con_id"), ora.ConName)
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
 		switch stat_name {
 		case "DB time":
-			ch <- prometheus.MustNewConstMetric(
-				oracleDbTimeDesc, prometheus.CounterValue, val, formatFloat64(conId), ora.ConName)
+			out.send(
+				oracleDbTimeDesc.get(ora), prometheus.CounterValue, val, labels...)
 		case "DB CPU":
-			ch <- prometheus.MustNewConstMetric(
-				oracleDbCpuDesc, prometheus.CounterValue, val, formatFloat64(conId), ora.ConName)
+			out.send(
+				oracleDbCpuDesc.get(ora), prometheus.CounterValue, val, labels...)
 		case "background cpu time":
-			ch <- prometheus.MustNewConstMetric(
-				oracleBackgroundCpuDesc, prometheus.CounterValue, val, formatFloat64(conId), ora.ConName)
+			out.send(
+				oracleBackgroundCpuDesc.get(ora), prometheus.CounterValue, val, labels...)
 		default:
-			ch <- prometheus.MustNewConstMetric(
-				oracleTimeModelDesc, prometheus.CounterValue, val, stat_name, formatFloat64(conId), ora.ConName)
+			out.send(
+				oracleTimeModelDesc.get(ora), prometheus.CounterValue, val, append([]string{stat_name}, labels...)...)
 		}
 
 	}
-	return nil
+	return out.err()
 }
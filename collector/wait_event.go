@@ -2,21 +2,22 @@ package collector
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"yunche.pro/dtsre/oracledb_exporter/dbutil"
 )
 
 var (
-	oracleWaitTotalEventDesc = prometheus.NewDesc(
+	oracleWaitTotalEventDesc = newInstanceDesc(
 		prometheus.BuildFQName(namespace, "wait", "total_event"),
 		"Oracle Waits",
-		[]string{"wait_class", "event", "con_id", "con_name"}, nil)
+		[]string{"wait_class", "event", "con_id", "con_name"})
 
-	oracleWaitTotalTimeDesc = prometheus.NewDesc(
+	oracleWaitTotalTimeDesc = newInstanceDesc(
 		prometheus.BuildFQName(namespace, "wait", "total_time"),
 		"Oracle Waited Time",
-		[]string{"wait_class", "event", "con_id", "con_name"}, nil)
+		[]string{"wait_class", "event", "con_id", "con_name"})
 )
 
 type ScrapeOracleWaitEvent struct{}
@@ -34,11 +35,15 @@ func (ScrapeOracleWaitEvent) Version() float64 {
 	return 10.2
 }
 
-func (ScrapeOracleWaitEvent) Scrape(ctx context.Context, dbcli *dbutil.OracleClient, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
+func (ScrapeOracleWaitEvent) Requirement() Requirement {
+	return Requirement{Mounted: true}
+}
+
+func (ScrapeOracleWaitEvent) Scrape(ctx context.Context, dbcli dbutil.Querier, ch chan<- prometheus.Metric, ora *InstanceInfoAll) error {
 	var sql string
 	if ora.VersionNum < 12.0 {
-		sql = `select event, wait_class, total_waits, time_waited, 0 as con_id  
-from v$system_event 
+		sql = `select %sevent, wait_class, total_waits, time_waited, 0 as con_id  
+from %s 
 where wait_class in (
     'Application',
     'Commit',
@@ -49,8 +54,8 @@ where wait_class in (
     'User I/O'
 )`
 	} else {
-		sql = `select event, wait_class, total_waits, time_waited, con_id  
-from v$system_event 
+		sql = `select %sevent, wait_class, total_waits, time_waited, con_id  
+from %s 
 where wait_class in (
     'Application',
     'Commit',
@@ -61,20 +66,29 @@ where wait_class in (
     'User I/O'
 )`
 	}
+	sql = fmt.Sprintf(sql, instanceColumn(ora, ""), instanceView(ora, "system_event"))
 
-	rows, err := dbcli.FetchRowsWithContext(ctx, sql)
+	rows, err := dbutil.FetchNamedRowsContext(ctx, dbcli, sql)
 	if err != nil {
 		return err
 	}
+	out := newMetricSender(ch)
 	for _, r := range rows {
-		event := r[0].(string)
-		class := r[1].(string)
-		conId := r[4].(float64)
-		ch <- prometheus.MustNewConstMetric(
-			oracleWaitTotalEventDesc, prometheus.CounterValue, r[2].(float64), class, event, formatFloat64(conId), ora.ConName)
+		row := out.row()
+		event := r.String("event")
+		class := r.String("wait_class")
+		labels := instanceLabels(ora, r, class, event, r.String("con_id"), ora.ConName)
+		totalWaits := r.Float("total_waits")
+		timeWaited := r.Float("time_waited")
+		if err := r.Err(); err != nil {
+			out.skip(err)
+			continue
+		}
+		row.send(
+			oracleWaitTotalEventDesc.get(ora), prometheus.CounterValue, totalWaits, labels...)
 
-		ch <- prometheus.MustNewConstMetric(
-			oracleWaitTotalTimeDesc, prometheus.CounterValue, r[3].(float64), class, event, formatFloat64(conId), ora.ConName)
+		row.send(
+			oracleWaitTotalTimeDesc.get(ora), prometheus.CounterValue, timeWaited, labels...)
 	}
-	return nil
+	return out.err()
 }
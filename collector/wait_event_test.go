@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
+)
+
+func TestScrapeOracleWaitEvent(t *testing.T) {
+	ora := &InstanceInfoAll{InstanceInfo: InstanceInfo{VersionNum: 19.0}, PdbInfo: PdbInfo{ConName: "CDB$ROOT", ConId: "1"}}
+	q := dbutil.NewFakeQuerier().
+		Add(`from v\$system_event`, []string{"event", "wait_class", "total_waits", "time_waited", "con_id"},
+			dbutil.Row{"log file sync", "Commit", 1500.0, 320.0, 1.0},
+			dbutil.Row{"db file sequential read", "User I/O", 9000.0, 4100.0, 1.0})
+
+	testScrape(t, ScrapeOracleWaitEvent{}, q, ora, `
+# HELP oracle_wait_total_event Oracle Waits
+# TYPE oracle_wait_total_event counter
+oracle_wait_total_event{con_id="1",con_name="CDB$ROOT",event="db file sequential read",wait_class="User I/O"} 9000
+oracle_wait_total_event{con_id="1",con_name="CDB$ROOT",event="log file sync",wait_class="Commit"} 1500
+# HELP oracle_wait_total_time Oracle Waited Time
+# TYPE oracle_wait_total_time counter
+oracle_wait_total_time{con_id="1",con_name="CDB$ROOT",event="db file sequential read",wait_class="User I/O"} 4100
+oracle_wait_total_time{con_id="1",con_name="CDB$ROOT",event="log file sync",wait_class="Commit"} 320
+`)
+}
+
+func TestScrapeBadRows(t *testing.T) {
+	ora := &InstanceInfoAll{InstanceInfo: InstanceInfo{VersionNum: 19.0}, PdbInfo: PdbInfo{ConName: "CDB$ROOT", ConId: "1"}}
+	q := dbutil.NewFakeQuerier().
+		Add(`from v\$system_event`, []string{"event", "wait_class", "total_waits", "time_waited", "con_id"},
+			dbutil.Row{"log file sync", "Commit", 1500.0, 320.0, 1.0},
+			dbutil.Row{"db file sequential read", "User I/O", nil, 4100.0, 1.0},
+			dbutil.Row{"latch: \xff", "Concurrency", 10.0, 2.0, 1.0})
+
+	c := &scraperCollector{scraper: ScrapeOracleWaitEvent{}, dbcli: q, ora: ora}
+	err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP oracle_wait_total_event Oracle Waits
+# TYPE oracle_wait_total_event counter
+oracle_wait_total_event{con_id="1",con_name="CDB$ROOT",event="log file sync",wait_class="Commit"} 1500
+# HELP oracle_wait_total_time Oracle Waited Time
+# TYPE oracle_wait_total_time counter
+oracle_wait_total_time{con_id="1",con_name="CDB$ROOT",event="log file sync",wait_class="Commit"} 320
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bad *BadRowsError
+	if !errors.As(c.err, &bad) {
+		t.Fatalf("scrape error %v, want bad rows", c.err)
+	}
+	// the NULL row, and the row with the invalid label once for its two metrics
+	if bad.Rows != 2 {
+		t.Errorf("bad rows %d, want 2: %s", bad.Rows, bad)
+	}
+}
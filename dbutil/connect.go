@@ -0,0 +1,131 @@
+package dbutil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/godror/godror"
+)
+
+var (
+	// the service or sid in the CONNECT_DATA of a connect descriptor
+	descriptorServicePattern = regexp.MustCompile(`(?i)\(\s*(SERVICE_NAME|SID)\s*=[^)]*\)`)
+	descriptionPattern       = regexp.MustCompile(`(?i)\(\s*DESCRIPTION\s*=`)
+	// [//]host[:port]/service[:server][/instance][?params]
+	easyConnectPattern = regexp.MustCompile(`^((?://)?[^/?]+)/([^:/?]*)(.*)$`)
+)
+
+// getConnectionStr returns the connect string of the current service. It is the dsn, a connect descriptor
+// or easy connect string built from the host, port and service name or sid, for a PDB the service of the
+// dsn is replaced with the service of the PDB.
+func (c *OracleClient) getConnectionStr() (string, error) {
+	var service string
+	if c.C.CurrentPdb != "" {
+		service = c.C.PdbServiceName(c.C.CurrentPdb)
+	}
+
+	dsn := strings.TrimSpace(c.C.Dsn)
+	switch {
+	case strings.HasPrefix(dsn, "("):
+		if service != "" {
+			dsn = descriptorServicePattern.ReplaceAllString(dsn, "(SERVICE_NAME="+service+")")
+		}
+		return descriptorWithTimeout(dsn, c.C.ConnectTimeout), nil
+	case easyConnectPattern.MatchString(dsn):
+		if service != "" {
+			dsn = easyConnectPattern.ReplaceAllString(dsn, "${1}/"+service+"${3}")
+		}
+		return easyConnectWithTimeout(dsn, c.C.ConnectTimeout), nil
+	case dsn != "" && (service == "" || c.C.Host == ""):
+		// a tns alias, the PDB connect string can only be derived from the host
+		if service != "" {
+			return "", fmt.Errorf("can not connect to pdb %s with tns alias %s, set host and port or use a connect descriptor", c.C.CurrentPdb, dsn)
+		}
+		return dsn, nil
+	}
+
+	if service == "" {
+		service = c.C.ServiceName
+	}
+	connectData := fmt.Sprintf("(SERVICE_NAME=%s)", service)
+	if service == "" && c.C.Sid != "" {
+		// easy connect has no sid, only a descriptor can connect to it
+		connectData = fmt.Sprintf("(SID=%s)", c.C.Sid)
+	} else if c.C.ConnectTimeout == 0 {
+		return fmt.Sprintf("%s:%d/%s", c.C.Host, c.C.Port, service), nil
+	}
+	descriptor := fmt.Sprintf("(DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=%s)(PORT=%d))(CONNECT_DATA=%s))",
+		c.C.Host, c.C.Port, connectData)
+	return descriptorWithTimeout(descriptor, c.C.ConnectTimeout), nil
+}
+
+func descriptorWithTimeout(descriptor string, timeout time.Duration) string {
+	if timeout <= 0 || strings.Contains(strings.ToUpper(descriptor), "CONNECT_TIMEOUT") {
+		return descriptor
+	}
+	seconds := timeoutSeconds(timeout)
+	return descriptionPattern.ReplaceAllString(descriptor,
+		fmt.Sprintf("${0}(CONNECT_TIMEOUT=%d)(TRANSPORT_CONNECT_TIMEOUT=%d)", seconds, seconds))
+}
+
+// easyConnectWithTimeout adds the connect_timeout parameter of easy connect plus, which needs a 19c client.
+func easyConnectWithTimeout(dsn string, timeout time.Duration) string {
+	if timeout <= 0 || strings.Contains(dsn, "connect_timeout") {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%sconnect_timeout=%d", dsn, sep, timeoutSeconds(timeout))
+}
+
+func timeoutSeconds(timeout time.Duration) int {
+	seconds := int((timeout + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// PdbServiceName returns the service name of the PDB.
+func (c *OracleConfig) PdbServiceName(pdb string) string {
+	if service, ok := c.PdbServices[pdb]; ok && service != "" {
+		return service
+	}
+	return pdb
+}
+
+// GetTimezone returns the location of the timezone setting.
+func (c *OracleConfig) GetTimezone() (*time.Location, error) {
+	switch strings.ToLower(c.Timezone) {
+	case "":
+		return nil, nil
+	case "local":
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// ValidateAdminRole checks that the driver supports the admin role.
+func (c *OracleConfig) ValidateAdminRole() error {
+	return c.setAdminRole(&godror.ConnectionParams{})
+}
+
+// setAdminRole sets the privilege of the admin role, one of the privileges of the godror connection params.
+func (c *OracleConfig) setAdminRole(params *godror.ConnectionParams) error {
+	switch strings.ToUpper(c.AdminRole) {
+	case "":
+	case "SYSDBA":
+		params.IsSysDBA = true
+	case "SYSOPER":
+		params.IsSysOper = true
+	case "SYSASM":
+		params.IsSysASM = true
+	default:
+		return fmt.Errorf("unknown adminRole %q, must be SYSDBA, SYSOPER or SYSASM", c.AdminRole)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package dbutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetConnectionStr(t *testing.T) {
+	descriptor := "(DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=scan)(PORT=1521))(CONNECT_DATA=(SERVICE_NAME=cdb1)))"
+	for _, tc := range []struct {
+		c    OracleConfig
+		pdb  string
+		want string
+		err  bool
+	}{
+		{c: OracleConfig{Host: "db1", Port: 1521, ServiceName: "cdb1"}, want: "db1:1521/cdb1"},
+		{c: OracleConfig{Host: "db1", Port: 1521, ServiceName: "cdb1"}, pdb: "pdb1", want: "db1:1521/pdb1"},
+		{c: OracleConfig{Host: "db1", Port: 1521, Sid: "orcl"},
+			want: "(DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=db1)(PORT=1521))(CONNECT_DATA=(SID=orcl)))"},
+		{c: OracleConfig{Host: "db1", Port: 1521, ServiceName: "cdb1", ConnectTimeout: 5 * time.Second},
+			want: "(DESCRIPTION=(CONNECT_TIMEOUT=5)(TRANSPORT_CONNECT_TIMEOUT=5)(ADDRESS=(PROTOCOL=TCP)(HOST=db1)(PORT=1521))(CONNECT_DATA=(SERVICE_NAME=cdb1)))"},
+		{c: OracleConfig{Dsn: "db1:1521/cdb1"}, pdb: "pdb1", want: "db1:1521/pdb1"},
+		{c: OracleConfig{Dsn: "//db1:1521/cdb1:dedicated/cdb11"}, pdb: "pdb1", want: "//db1:1521/pdb1:dedicated/cdb11"},
+		{c: OracleConfig{Dsn: "db1:1521/cdb1", ConnectTimeout: 3 * time.Second}, want: "db1:1521/cdb1?connect_timeout=3"},
+		{c: OracleConfig{Dsn: descriptor}, want: descriptor},
+		{c: OracleConfig{Dsn: descriptor, PdbServices: map[string]string{"PDB1": "pdb1.example.com"}}, pdb: "PDB1",
+			want: "(DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=scan)(PORT=1521))(CONNECT_DATA=(SERVICE_NAME=pdb1.example.com)))"},
+		{c: OracleConfig{Dsn: "cdb1_alias"}, want: "cdb1_alias"},
+		{c: OracleConfig{Dsn: "cdb1_alias", Host: "db1", Port: 1521}, pdb: "pdb1", want: "db1:1521/pdb1"},
+		{c: OracleConfig{Dsn: "cdb1_alias"}, pdb: "pdb1", err: true},
+	} {
+		cli := NewOracleClient(tc.c)
+		if tc.pdb != "" {
+			cli = cli.WithPdb(tc.pdb)
+		}
+		got, err := cli.getConnectionStr()
+		if tc.err {
+			if err == nil {
+				t.Errorf("%+v pdb %q: no error", tc.c, tc.pdb)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%+v pdb %q: got %q %v, want %q", tc.c, tc.pdb, got, err, tc.want)
+		}
+	}
+}
+
+func TestAdminRole(t *testing.T) {
+	params, err := NewOracleClient(OracleConfig{Dsn: "db1:1521/cdb1", Username: "sys", Password: "secret", AdminRole: "sysdba"}).connectionParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !params.IsSysDBA || !params.IsStandalone() {
+		t.Fatalf("unexpected params: %s", params)
+	}
+
+	for _, role := range []string{"SYSDG", "sysbackup", "SYSKM", "dba"} {
+		c := OracleConfig{AdminRole: role}
+		if err := c.ValidateAdminRole(); err == nil {
+			t.Errorf("adminRole %s accepted", role)
+		}
+	}
+}
@@ -4,132 +4,214 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"io/ioutil"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
-	_ "github.com/godror/godror"
-	"gopkg.in/yaml.v2"
+	"github.com/godror/godror"
 )
 
 type OracleConfig struct {
-	Dsn         string
-	Host        string
-	Port        int
-	Username    string
-	Password    string
-	ServiceName string
-	Sid         string
-	Pdbs        []string
-	CurrentPdb  string
+	Name        string   `yaml:"name"`
+	Dsn         string   `yaml:"dsn"`
+	Host        string   `yaml:"host"`
+	Port        int      `yaml:"port"`
+	Username    string   `yaml:"username"`
+	Password    string   `yaml:"password"`
+	ServiceName string   `yaml:"serviceName"`
+	Sid         string   `yaml:"sid"`
+	Pdbs        []string `yaml:"pdbs"`
+	CurrentPdb  string   `yaml:"-"`
+	// PdbDiscovery scrapes the open PDBs found in v$pdbs instead of Pdbs
+	PdbDiscovery PdbDiscoveryConfig `yaml:"pdbDiscovery"`
+	// PdbConcurrency is the number of PDBs scraped in parallel
+	PdbConcurrency int `yaml:"pdbConcurrency"`
+	// CollectorConcurrency is the number of collectors of a CDB/PDB run in parallel, each with its own connection
+	CollectorConcurrency int `yaml:"collectorConcurrency"`
+	// MaxScrapesInFlight limits the different collections of the target running at a time,
+	// concurrent scrapes of the same collectors share one collection. 0 is unlimited.
+	MaxScrapesInFlight int `yaml:"maxScrapesInFlight"`
+	// ScrapeTimeout limits a collection of the target, which is shared by the concurrent scrapes and does not
+	// end with the request which started it
+	ScrapeTimeout time.Duration `yaml:"scrapeTimeout"`
+	// PasswordFile is a file holding the password, read on each connect
+	PasswordFile string `yaml:"passwordFile"`
+	// ExternalAuth connects without username and password, with the credentials of an oracle wallet
+	ExternalAuth bool `yaml:"externalAuth"`
+	// ConfigDir is the TNS_ADMIN directory with tnsnames.ora, sqlnet.ora and the wallet
+	ConfigDir string `yaml:"configDir"`
+	// PdbServices maps PDB names to their service names, if they differ
+	PdbServices map[string]string `yaml:"pdbServices"`
+	// Timezone is the timezone of DATE values, local, UTC or a location name like Asia/Shanghai
+	Timezone string `yaml:"timezone"`
+	// ConnectTimeout limits the time to establish a connection
+	ConnectTimeout time.Duration `yaml:"connectTimeout"`
+	// Standalone uses standalone connections instead of an oracle session pool
+	Standalone bool `yaml:"standalone"`
+	// AdminRole connects with an administrative privilege like SYSDBA, needed to query a MOUNTED standby
+	AdminRole string `yaml:"adminRole"`
+	// DisableRac scrapes the v$ views of the connected instance of a RAC instead of the gv$ views of all
+	// instances, e.g. when each instance has its own exporter
+	DisableRac bool `yaml:"disableRac"`
 }
 
+const (
+	defaultPdbConcurrency       = 4
+	defaultCollectorConcurrency = 1
+	defaultScrapeTimeout        = time.Minute
+)
+
+type PdbDiscoveryConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Include and Exclude are regular expressions matched against the PDB name
+	Include string `yaml:"include"`
+	Exclude string `yaml:"exclude"`
+}
+
+func (c *OracleConfig) GetPdbConcurrency() int {
+	if c.PdbConcurrency > 0 {
+		return c.PdbConcurrency
+	}
+	return defaultPdbConcurrency
+}
+
+func (c *OracleConfig) GetCollectorConcurrency() int {
+	if c.CollectorConcurrency > 0 {
+		return c.CollectorConcurrency
+	}
+	return defaultCollectorConcurrency
+}
+
+func (c *OracleConfig) GetScrapeTimeout() time.Duration {
+	if c.ScrapeTimeout > 0 {
+		return c.ScrapeTimeout
+	}
+	return defaultScrapeTimeout
+}
+
+// Querier runs the queries of the collectors, it is implemented by OracleClient and FakeQuerier.
+type Querier interface {
+	FetchRowsWithContext(ctx context.Context, querytext string, params ...interface{}) ([]Row, error)
+	// FetchRowsWithColumnsContext returns the rows of the query with the lower case column names.
+	FetchRowsWithColumnsContext(ctx context.Context, querytext string, params ...interface{}) ([]string, []Row, error)
+}
+
+// OracleClient queries one CDB/PDB service of a database.
+// Connection pools are kept open across scrapes and shared with the clients returned by WithPdb.
 type OracleClient struct {
-	configFile string
-	C          OracleConfig
-	dbconn     *sql.DB
+	C     OracleConfig
+	pools *poolSet
 }
 
 type Row []interface{}
 
-func NewOracleClient(configFile string) *OracleClient {
+func NewOracleClient(c OracleConfig) *OracleClient {
 
-	cli := OracleClient{configFile: configFile}
+	cli := OracleClient{C: c, pools: newPoolSet()}
 
 	return &cli
 
 }
 
-func (c *OracleClient) Init() error {
-	err := c.initConfig()
-	if err != nil {
-		return err
+// Init makes sure the pool of the current service is connected and alive.
+func (c *OracleClient) Init(ctx context.Context) error {
+	if fixtureMode == fixturesReplay {
+		return nil
 	}
+	return c.pool().validate(ctx, c.Connect)
+}
 
-	err = c.initConnection()
-	if err != nil {
-		return err
-	}
+// WithPdb returns a client of the pdb service sharing the connection pools of c.
+func (c *OracleClient) WithPdb(pdb string) *OracleClient {
+	cli := OracleClient{C: c.C, pools: c.pools}
+	cli.setCurrentPdb(pdb)
+	return &cli
+}
 
-	return err
+// Close closes the connection pools of all services.
+func (c *OracleClient) Close() error {
+	return c.pools.close()
 }
 
-func (c *OracleClient) ReInitWithPdb(pdb string) error {
-	err := c.CloseConnection()
-	if err != nil {
-		return err
+// RetainPdbs closes the connection pools of the PDBs which are not in pdbs.
+func (c *OracleClient) RetainPdbs(pdbs []string) {
+	keep := map[string]bool{"": true}
+	for _, pdb := range pdbs {
+		keep[pdb] = true
 	}
-	c.setCurrentPdb(pdb)
-	err = c.initConnection()
-	return err
+	c.pools.retain(keep)
 }
 
-func (c *OracleClient) CloseConnection() error {
-	if c.dbconn != nil {
-		err := c.dbconn.Close()
-		return err
-	}
-	return nil
+// Stats returns the statistics of the connection pools of all services.
+func (c *OracleClient) Stats() []PoolStats {
+	return c.pools.stats()
+}
+
+func (c *OracleClient) pool() *pool {
+	return c.pools.get(c.C.CurrentPdb, c.getServiceName())
 }
 
-func (c *OracleClient) initConfig() error {
-	buf, err := ioutil.ReadFile(c.configFile)
+func (c *OracleClient) Connect() (*sql.DB, error) {
+	params, err := c.connectionParams()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	log.WithFields(log.Fields{"conn str": params.ConnectString, "user": params.Username, "externalAuth": params.ExternalAuth}).Info("Connect to Oracle")
 
-	err = yaml.Unmarshal(buf, &c.C)
-	return err
+	db := sql.OpenDB(godror.NewConnector(params))
+	db.SetMaxIdleConns(c.C.GetCollectorConcurrency())
+	db.SetMaxOpenConns(c.C.GetCollectorConcurrency())
+	return db, nil
 }
 
-func (c *OracleClient) initConnection() error {
-	conn, err := c.Connect()
+// connectionParams returns the godror connection parameters, the password is passed as is without quoting.
+func (c *OracleClient) connectionParams() (godror.ConnectionParams, error) {
+	params, err := godror.ParseDSN("")
 	if err != nil {
-		return err
+		return params, err
 	}
-
-	err = conn.Ping()
+	params.ConnectString, err = c.getConnectionStr()
 	if err != nil {
-		return err
+		return params, err
 	}
-
-	c.dbconn = conn
-	return nil
-}
-
-func (c *OracleClient) Connect() (*sql.DB, error) {
-	// fmt.Printf("\nget new Connect\n")
-	dsn := c.getDatasource()
-	log.WithFields(log.Fields{"conn str": c.getConnectionStr(), "user": c.C.Username}).Info("Connect to Oracle")
-
-	db, err := sql.Open("godror", dsn)
+	params.ConfigDir = c.C.ConfigDir
+	params.StandaloneConnection = c.C.Standalone
+	err = c.C.setAdminRole(&params)
 	if err != nil {
-		return nil, err
+		return params, err
+	}
+	if c.C.Timezone != "" {
+		params.Timezone, err = c.C.GetTimezone()
+		if err != nil {
+			return params, err
+		}
+	}
+	params.ExternalAuth = c.C.ExternalAuth
+	if c.C.ExternalAuth {
+		return params, nil
 	}
-	db.SetMaxIdleConns(1)
-	db.SetMaxOpenConns(1)
-	return db, nil
-}
 
-func (c *OracleClient) getDatasource() string {
-	connStr := c.getConnectionStr()
-	dsn := fmt.Sprintf(`user="%s" password="%s" connectString="%s"`, c.C.Username, c.C.Password, connStr)
-	return dsn
+	password, err := c.C.GetPassword()
+	if err != nil {
+		return params, err
+	}
+	params.Username = c.C.Username
+	params.Password = godror.NewPassword(password)
+	return params, nil
 }
 
-func (c *OracleClient) getConnectionStr() string {
+func (c *OracleClient) getServiceName() string {
 	if c.C.CurrentPdb != "" {
-		connStr := fmt.Sprintf("%s:%d/%s", c.C.Host, c.C.Port, c.C.CurrentPdb)
-		return connStr
+		return c.C.CurrentPdb
 	}
-
-	if c.C.Dsn != "" {
-		return c.C.Dsn
-	} else {
-		connStr := fmt.Sprintf("%s:%d/%s", c.C.Host, c.C.Port, c.C.ServiceName)
-		return connStr
+	if c.C.ServiceName != "" {
+		return c.C.ServiceName
 	}
-
+	if c.C.Sid != "" {
+		return c.C.Sid
+	}
+	return c.C.Dsn
 }
 
 func (c *OracleClient) setCurrentPdb(pdb string) {
@@ -142,22 +224,43 @@ func (c *OracleClient) ExecuteQuery(querytext string, params ...interface{}) (*s
 }
 
 func (c *OracleClient) FetchRowsWithContext(ctx context.Context, querytext string, params ...interface{}) ([]Row, error) {
+	_, rows, err := c.FetchRowsWithColumnsContext(ctx, querytext, params...)
+	return rows, err
+}
+
+// FetchRowsWithColumnsContext returns the rows of the query with the lower case column names.
+func (c *OracleClient) FetchRowsWithColumnsContext(ctx context.Context, querytext string, params ...interface{}) ([]string, []Row, error) {
+	switch fixtureMode {
+	case fixturesReplay:
+		return fixtures.lookup(c.C.Name, c.C.CurrentPdb, querytext, params)
+	case fixturesRecord:
+		columns, rows, err := c.fetchRowsWithColumns(ctx, querytext, params...)
+		// a cancelled scrape is not the result of the query
+		if ctx.Err() == nil {
+			fixtures.record(c.C.Name, c.C.CurrentPdb, querytext, params, columns, rows, err)
+		}
+		return columns, rows, err
+	}
+	return c.fetchRowsWithColumns(ctx, querytext, params...)
+}
+
+func (c *OracleClient) fetchRowsWithColumns(ctx context.Context, querytext string, params ...interface{}) ([]string, []Row, error) {
 	rs, err := c.ExecuteQueryWithContext(ctx, querytext, params...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rs.Close()
 
-	rows, err := fetchRows(rs)
-	return rows, err
+	return fetchRows(rs)
 }
 
 func (c *OracleClient) ExecuteQueryWithContext(ctx context.Context, querytext string, params ...interface{}) (*sql.Rows, error) {
-	if c.dbconn == nil {
+	dbconn := c.pool().conn()
+	if dbconn == nil {
 		return nil, fmt.Errorf("DB Connection is Nil")
 	}
 
-	rows, err := c.dbconn.QueryContext(ctx, querytext, params...)
+	rows, err := dbconn.QueryContext(ctx, querytext, params...)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err, "query": querytext}).Warn("Execute Query")
 	}
@@ -165,10 +268,14 @@ func (c *OracleClient) ExecuteQueryWithContext(ctx context.Context, querytext st
 
 }
 
-func fetchRows(rows *sql.Rows) ([]Row, error) {
+func fetchRows(rows *sql.Rows) ([]string, []Row, error) {
 	var ret []Row
 
 	columnTypes, _ := rows.ColumnTypes()
+	var columns []string
+	for _, col := range columnTypes {
+		columns = append(columns, strings.ToLower(col.Name()))
+	}
 	// for _, col_type := range columnTypes {
 	// 	fmt.Printf("Column Types:%s %v\n", col_type.Name(), col_type.ScanType())
 	// }
@@ -184,7 +291,7 @@ func fetchRows(rows *sql.Rows) ([]Row, error) {
 		err := rows.Scan(n...)
 		if err != nil {
 			log.WithFields(log.Fields{"error": err}).Error("Scan Row Error")
-			return nil, err
+			return nil, nil, err
 		}
 		for i, _ := range columnTypes {
 			log.WithFields(log.Fields{"column index": i,
@@ -198,7 +305,7 @@ func fetchRows(rows *sql.Rows) ([]Row, error) {
 		}
 		ret = append(ret, r)
 	}
-	return ret, nil
+	return columns, ret, rows.Err()
 }
 
 func DumpRows(rows *sql.Rows) {
@@ -229,54 +336,75 @@ func DumpRows(rows *sql.Rows) {
 	}
 }
 
+// The values of fetched rows are typed by the oracle type of their column, as named by the driver
+// (godror reports INTEGER as NUMBER, and BINARY_FLOAT, BINARY_DOUBLE as FLOAT, DOUBLE), NULL is nil for all types:
+//
+//	VARCHAR2, NVARCHAR2, CHAR, NCHAR, LONG, CLOB, NCLOB, ROWID                string
+//	NUMBER, FLOAT, DOUBLE, BINARY_INTEGER                                     float64
+//	DATE, TIMESTAMP, TIMESTAMP WITH TIME ZONE, TIMESTAMP WITH LOCAL TIME ZONE time.Time
+//	INTERVAL DAY TO SECOND                                                    float64, in seconds
+//	INTERVAL YEAR TO MONTH                                                    string, like 1-6
+//	RAW, LONG RAW, BLOB                                                       string, hex encoded
+//	BOOLEAN                                                                   float64, 1 for true
+//
+// Other types, like BFILE, JSON or OBJECT, are returned as scanned by the driver.
 func getField(typename string) interface{} {
 	switch typename {
-	// case "VARCHAR2":
-	// 	return new(sql.NullString)
-	case "VARCHAR":
-		return new(sql.NullString)
-	case "CHAR":
+	case "VARCHAR2", "NVARCHAR2", "CHAR", "NCHAR", "LONG", "CLOB", "NCLOB", "ROWID", "INTERVAL YEAR TO MONTH":
 		return new(sql.NullString)
-	case "CLOB":
-		return new(sql.NullString)
-	case "NUMBER":
+	case "NUMBER", "FLOAT", "DOUBLE", "BINARY_INTEGER":
 		return new(sql.NullFloat64)
-	case "RAW":
-		return new([]byte)
-	case "TIMESTAMP":
-		return new(sql.NullTime)
-	case "DATE":
+	case "DATE", "TIMESTAMP", "TIMESTAMP WITH TIME ZONE", "TIMESTAMP WITH LOCAL TIME ZONE":
 		return new(sql.NullTime)
+	case "RAW", "LONG RAW", "BLOB":
+		return new([]byte)
+	case "BOOLEAN":
+		return new(sql.NullBool)
 	}
+	// INTERVAL DAY TO SECOND is scanned as time.Duration
 	return new(interface{})
 }
 
 func getFieldValue(val interface{}, typename string) interface{} {
 	switch typename {
-	// case "VARCHAR2":
-	// 	return *val.(*sql.NullString)
-	case "VARCHAR":
-		return *val.(*sql.NullString)
-	case "CHAR":
-		return *val.(*sql.NullString)
-	case "CLOB":
-		return *val.(*sql.NullString)
-	case "NUMBER":
-		v1 := *val.(*sql.NullFloat64)
-		var v2 interface{}
-		if v1.Valid {
-			v2 = v1.Float64
-		} else {
-			v2 = -1.0
+	case "VARCHAR2", "NVARCHAR2", "CHAR", "NCHAR", "LONG", "CLOB", "NCLOB", "ROWID", "INTERVAL YEAR TO MONTH":
+		v := *val.(*sql.NullString)
+		if !v.Valid {
+			return nil
+		}
+		return v.String
+	case "NUMBER", "FLOAT", "DOUBLE", "BINARY_INTEGER":
+		// NULL is nil, to be told apart from any number
+		v := *val.(*sql.NullFloat64)
+		if !v.Valid {
+			return nil
+		}
+		return v.Float64
+	case "DATE", "TIMESTAMP", "TIMESTAMP WITH TIME ZONE", "TIMESTAMP WITH LOCAL TIME ZONE":
+		v := *val.(*sql.NullTime)
+		if !v.Valid {
+			return nil
+		}
+		return v.Time
+	case "RAW", "LONG RAW", "BLOB":
+		v := *val.(*[]byte)
+		if v == nil {
+			return nil
+		}
+		return fmt.Sprintf("%x", v)
+	case "BOOLEAN":
+		v := *val.(*sql.NullBool)
+		if !v.Valid {
+			return nil
+		}
+		if v.Bool {
+			return 1.0
+		}
+		return 0.0
+	case "INTERVAL DAY TO SECOND":
+		if d, ok := (*val.(*interface{})).(time.Duration); ok {
+			return d.Seconds()
 		}
-		return v2
-
-	case "RAW":
-		return fmt.Sprintf("%x", *val.(*[]byte))
-	case "TIMESTAMP":
-		return *val.(*sql.NullTime)
-	case "DATE":
-		return *val.(*sql.NullTime)
 	}
 	return *val.(*interface{})
 }
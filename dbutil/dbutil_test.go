@@ -0,0 +1,69 @@
+package dbutil
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// scanField scans src like the driver into the field of the type.
+func scanField(t *testing.T, typename string, src interface{}) interface{} {
+	t.Helper()
+	field := getField(typename)
+	switch f := field.(type) {
+	case sql.Scanner:
+		if err := f.Scan(src); err != nil {
+			t.Fatalf("%s: scan %#v: %s", typename, src, err)
+		}
+	case *[]byte:
+		if src != nil {
+			*f = src.([]byte)
+		}
+	case *interface{}:
+		*f = src
+	}
+	return getFieldValue(field, typename)
+}
+
+func TestFieldValue(t *testing.T) {
+	created := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		typename string
+		src      interface{}
+		want     interface{}
+	}{
+		{"VARCHAR2", "SYSTEM", "SYSTEM"},
+		{"NVARCHAR2", "数据", "数据"},
+		{"CHAR", "Y", "Y"},
+		{"NCHAR", "N", "N"},
+		{"LONG", "text", "text"},
+		{"CLOB", "select 1 from dual", "select 1 from dual"},
+		{"NCLOB", "clob", "clob"},
+		{"ROWID", "AAAR3sAAEAAAACXAAA", "AAAR3sAAEAAAACXAAA"},
+		{"NUMBER", "1024", 1024.0},
+		{"FLOAT", float32(1.5), 1.5},
+		{"DOUBLE", 2.25, 2.25},
+		{"BINARY_INTEGER", int64(-1), -1.0},
+		{"DATE", created, created},
+		{"TIMESTAMP", created, created},
+		{"TIMESTAMP WITH TIME ZONE", created, created},
+		{"TIMESTAMP WITH LOCAL TIME ZONE", created, created},
+		{"INTERVAL DAY TO SECOND", 90 * time.Second, 90.0},
+		{"RAW", []byte{0xca, 0xfe}, "cafe"},
+		{"LONG RAW", []byte{0x01}, "01"},
+		{"BLOB", []byte{0xff, 0x00}, "ff00"},
+		{"INTERVAL YEAR TO MONTH", "1-6", "1-6"},
+		{"BOOLEAN", true, 1.0},
+		{"BOOLEAN", false, 0.0},
+		{"JSON", map[string]interface{}{"a": 1.0}, map[string]interface{}{"a": 1.0}},
+	}
+	for _, c := range cases {
+		if got := scanField(t, c.typename, c.src); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %#v, want %#v", c.typename, got, c.want)
+		}
+		if got := scanField(t, c.typename, nil); got != nil {
+			t.Errorf("%s: NULL is %#v, want nil", c.typename, got)
+		}
+	}
+}
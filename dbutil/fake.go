@@ -0,0 +1,79 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+var spacePattern = regexp.MustCompile(`\s+`)
+
+// FakeQuerier is an in-memory Querier returning canned rows, to test collectors without a database.
+// The values of the rows are typed like the ones of OracleClient: float64 for NUMBER, string for VARCHAR2,
+// time.Time for DATE and nil for NULL.
+type FakeQuerier struct {
+	mu      sync.Mutex
+	results []fakeResult
+	queries []string
+}
+
+type fakeResult struct {
+	pattern *regexp.Regexp
+	columns []string
+	rows    []Row
+	err     error
+}
+
+func NewFakeQuerier() *FakeQuerier {
+	return &FakeQuerier{}
+}
+
+// Add returns the rows for the queries matching pattern. The pattern is a case insensitive regular
+// expression matched against the query with its whitespace collapsed to single spaces.
+// Results are matched in the order they are added.
+func (f *FakeQuerier) Add(pattern string, columns []string, rows ...Row) *FakeQuerier {
+	return f.add(fakeResult{pattern: regexp.MustCompile("(?i)" + pattern), columns: columns, rows: rows})
+}
+
+// AddError fails the queries matching pattern with err.
+func (f *FakeQuerier) AddError(pattern string, err error) *FakeQuerier {
+	return f.add(fakeResult{pattern: regexp.MustCompile("(?i)" + pattern), err: err})
+}
+
+func (f *FakeQuerier) add(r fakeResult) *FakeQuerier {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, r)
+	return f
+}
+
+// Queries returns the queries run so far, with their whitespace collapsed.
+func (f *FakeQuerier) Queries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.queries...)
+}
+
+func (f *FakeQuerier) FetchRowsWithContext(ctx context.Context, querytext string, params ...interface{}) ([]Row, error) {
+	_, rows, err := f.FetchRowsWithColumnsContext(ctx, querytext, params...)
+	return rows, err
+}
+
+func (f *FakeQuerier) FetchRowsWithColumnsContext(ctx context.Context, querytext string, params ...interface{}) ([]string, []Row, error) {
+	query := normalizeQuery(querytext)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, query)
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	for _, r := range f.results {
+		if r.pattern.MatchString(query) {
+			return r.columns, r.rows, r.err
+		}
+	}
+	return nil, nil, fmt.Errorf("fake querier: no result for query %q", query)
+}
@@ -0,0 +1,298 @@
+package dbutil
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fixtures are recorded query results keyed by target, pdb and query. While recording, the results of
+// all queries are kept, while replaying queries are answered from the fixtures without connecting to Oracle.
+type Fixtures struct {
+	mu      sync.Mutex
+	file    string
+	entries map[string]*Fixture
+	dirty   bool
+}
+
+// Fixture is the result of one query.
+type Fixture struct {
+	Target  string           `json:"target"`
+	Pdb     string           `json:"pdb,omitempty"`
+	Query   string           `json:"query"`
+	Params  string           `json:"params,omitempty"`
+	Columns []string         `json:"columns"`
+	Rows    [][]FixtureValue `json:"rows"`
+	Error   string           `json:"error,omitempty"`
+}
+
+type fixtureFile struct {
+	Fixtures []*Fixture `json:"fixtures"`
+}
+
+// fixture modes
+const (
+	fixturesOff = iota
+	fixturesRecord
+	fixturesReplay
+)
+
+var (
+	fixtureMode int
+	fixtures    *Fixtures
+)
+
+// Record records the results of the queries of all clients into f, a nil f stops recording.
+func Record(f *Fixtures) {
+	setFixtures(fixturesRecord, f)
+}
+
+// Replay answers the queries of all clients from f instead of connecting to Oracle,
+// a nil f connects to Oracle again.
+func Replay(f *Fixtures) {
+	setFixtures(fixturesReplay, f)
+}
+
+func setFixtures(mode int, f *Fixtures) {
+	if f == nil {
+		mode = fixturesOff
+	}
+	fixtureMode, fixtures = mode, f
+}
+
+// SaveRecording writes the recorded fixtures to their file, if recording.
+func SaveRecording() error {
+	if fixtureMode != fixturesRecord {
+		return nil
+	}
+	return fixtures.Save()
+}
+
+// NewFixtures returns empty fixtures saved to file.
+func NewFixtures(file string) *Fixtures {
+	return &Fixtures{file: file, entries: make(map[string]*Fixture)}
+}
+
+func LoadFixtures(file string) (*Fixtures, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	ff := fixtureFile{}
+	err = json.Unmarshal(buf, &ff)
+	if err != nil {
+		return nil, fmt.Errorf("parse fixtures %s: %s", file, err)
+	}
+
+	f := NewFixtures(file)
+	for _, e := range ff.Fixtures {
+		f.entries[fixtureKey(e.Target, e.Pdb, e.Query, e.Params)] = e
+	}
+	return f, nil
+}
+
+// Save writes the fixtures sorted by key to a temporary file and renames it to the fixture file.
+func (f *Fixtures) Save() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.dirty {
+		return nil
+	}
+
+	keys := make([]string, 0, len(f.entries))
+	for k := range f.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ff := fixtureFile{}
+	for _, k := range keys {
+		ff.Fixtures = append(ff.Fixtures, f.entries[k])
+	}
+	buf, err := json.MarshalIndent(ff, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.file), filepath.Base(f.file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(buf, '\n'))
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = os.Rename(tmp.Name(), f.file)
+	if err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
+}
+
+func (f *Fixtures) record(target string, pdb string, query string, params []interface{}, columns []string, rows []Row, queryErr error) {
+	e := &Fixture{Target: target, Pdb: pdb, Query: normalizeQuery(query), Params: formatParams(params), Columns: columns}
+	if queryErr != nil {
+		e.Error = queryErr.Error()
+	}
+	for _, r := range rows {
+		values := make([]FixtureValue, len(r))
+		for i, v := range r {
+			values[i] = FixtureValue{v}
+		}
+		e.Rows = append(e.Rows, values)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[fixtureKey(e.Target, e.Pdb, e.Query, e.Params)] = e
+	f.dirty = true
+}
+
+func (f *Fixtures) lookup(target string, pdb string, query string, params []interface{}) ([]string, []Row, error) {
+	f.mu.Lock()
+	e, ok := f.entries[fixtureKey(target, pdb, normalizeQuery(query), formatParams(params))]
+	f.mu.Unlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("no recorded result of target %q pdb %q for query %q", target, pdb, normalizeQuery(query))
+	}
+	if e.Error != "" {
+		return nil, nil, errors.New(e.Error)
+	}
+
+	var rows []Row
+	for _, values := range e.Rows {
+		r := make(Row, len(values))
+		for i, v := range values {
+			r[i] = v.Value
+		}
+		rows = append(rows, r)
+	}
+	return e.Columns, rows, nil
+}
+
+func fixtureKey(target string, pdb string, query string, params string) string {
+	return strings.Join([]string{target, pdb, query, params}, "\x00")
+}
+
+func normalizeQuery(query string) string {
+	return strings.TrimSpace(spacePattern.ReplaceAllString(query, " "))
+}
+
+func formatParams(params []interface{}) string {
+	if len(params) == 0 {
+		return ""
+	}
+	return fmt.Sprint(params...)
+}
+
+// FixtureValue is a value of a row, encoded with its type so that it is replayed as the same go type.
+// A value of another type, which the driver returns as scanned, is recorded with its go type and
+// formatted by fmt.Sprint, and replayed as that string.
+type FixtureValue struct {
+	Value interface{}
+}
+
+type taggedValue struct {
+	Type  string      `json:"t"`
+	Value interface{} `json:"v,omitempty"`
+	Valid bool        `json:"valid,omitempty"`
+	// GoType is the go type of an other value
+	GoType string `json:"go,omitempty"`
+}
+
+// fixture value types
+const (
+	fixtureNull       = "null"
+	fixtureNumber     = "number"
+	fixtureString     = "string"
+	fixtureTime       = "time"
+	fixtureNullString = "nullstring"
+	fixtureNullTime   = "nulltime"
+	fixtureOther      = "other"
+)
+
+func (v FixtureValue) MarshalJSON() ([]byte, error) {
+	var t taggedValue
+	switch val := v.Value.(type) {
+	case nil:
+		t = taggedValue{Type: fixtureNull}
+	case float64:
+		t = taggedValue{Type: fixtureNumber, Value: val}
+	case string:
+		t = taggedValue{Type: fixtureString, Value: val}
+	case time.Time:
+		t = taggedValue{Type: fixtureTime, Value: val.Format(time.RFC3339Nano)}
+	case sql.NullString:
+		t = taggedValue{Type: fixtureNullString, Value: val.String, Valid: val.Valid}
+	case sql.NullTime:
+		t = taggedValue{Type: fixtureNullTime, Value: val.Time.Format(time.RFC3339Nano), Valid: val.Valid}
+	default:
+		t = taggedValue{Type: fixtureOther, Value: fmt.Sprint(val), GoType: fmt.Sprintf("%T", val)}
+	}
+	return json.Marshal(t)
+}
+
+func (v *FixtureValue) UnmarshalJSON(buf []byte) error {
+	var t struct {
+		Type  string          `json:"t"`
+		Value json.RawMessage `json:"v"`
+		Valid bool            `json:"valid"`
+	}
+	err := json.Unmarshal(buf, &t)
+	if err != nil {
+		return err
+	}
+
+	var s string
+	switch t.Type {
+	case fixtureNull:
+		v.Value = nil
+		return nil
+	case fixtureNumber:
+		var f float64
+		err = json.Unmarshal(t.Value, &f)
+		v.Value = f
+		return err
+	}
+
+	if len(t.Value) > 0 {
+		err = json.Unmarshal(t.Value, &s)
+		if err != nil {
+			return err
+		}
+	}
+	switch t.Type {
+	case fixtureString, fixtureOther:
+		v.Value = s
+	case fixtureNullString:
+		v.Value = sql.NullString{String: s, Valid: t.Valid}
+	case fixtureTime:
+		v.Value, err = time.Parse(time.RFC3339Nano, s)
+		return err
+	case fixtureNullTime:
+		var tm time.Time
+		if s != "" {
+			tm, err = time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return err
+			}
+		}
+		v.Value = sql.NullTime{Time: tm, Valid: t.Valid}
+	default:
+		return fmt.Errorf("unknown fixture value type %q", t.Type)
+	}
+	return nil
+}
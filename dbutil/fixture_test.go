@@ -0,0 +1,90 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFixtures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "fixtures.json")
+
+	created := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	rows := []Row{
+		{"SYSTEM", 1024.0, nil, sql.NullString{String: "Y", Valid: true}, sql.NullTime{Time: created, Valid: true}, created},
+		{"USERS", 0.0, nil, sql.NullString{}, sql.NullTime{}, nil},
+	}
+	f := NewFixtures(file)
+	f.record("db1", "", "select tablespace_name, bytes\n  from dba_data_files", nil, []string{"tablespace_name", "bytes"}, rows, nil)
+	f.record("db1", "PDB1", "select 1 from dual", []interface{}{"x"}, nil, nil, errors.New("ORA-00942: table or view does not exist"))
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = LoadFixtures(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Replay(f)
+	defer Replay(nil)
+
+	cli := NewOracleClient(OracleConfig{Name: "db1"})
+	if err := cli.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	columns, got, err := cli.FetchRowsWithColumnsContext(context.Background(), "select tablespace_name, bytes from dba_data_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(columns, []string{"tablespace_name", "bytes"}) || !reflect.DeepEqual(got, rows) {
+		t.Fatalf("replayed %v %#v", columns, got)
+	}
+
+	_, _, err = cli.WithPdb("PDB1").FetchRowsWithColumnsContext(context.Background(), "select 1 from dual", "x")
+	if err == nil || err.Error() != "ORA-00942: table or view does not exist" {
+		t.Fatalf("replayed error %v", err)
+	}
+	if _, err := cli.FetchRowsWithContext(context.Background(), "select 2 from dual"); err == nil {
+		t.Fatal("query without fixture replayed")
+	}
+}
+
+// TestFixturesOtherType records values of types without a fixture type, like JSON or OBJECT columns,
+// which are replayed as their formatted string.
+func TestFixturesOtherType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "fixtures.json")
+
+	f := NewFixtures(file)
+	f.record("db1", "", "select flag, raw from t", nil, []string{"flag", "raw"}, []Row{{true, int64(42)}}, nil)
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = LoadFixtures(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rows, err := f.lookup("db1", "", "select flag, raw from t", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, []Row{{"true", "42"}}) {
+		t.Fatalf("replayed %#v", rows)
+	}
+}
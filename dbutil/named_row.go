@@ -0,0 +1,143 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrNull is the error of reading a NULL value as a number.
+var ErrNull = errors.New("NULL value")
+
+// NamedRow is a row of a query result whose values are read by column name.
+// A value which can not be read sets the error of the row, Err returns the first one,
+// so that a row can be read completely and checked once.
+type NamedRow struct {
+	index map[string]int
+	row   Row
+	err   error
+}
+
+// NamedRows returns the rows with the lower case column names of their query.
+func NamedRows(columns []string, rows []Row) []*NamedRow {
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[col] = i
+	}
+	named := make([]*NamedRow, 0, len(rows))
+	for _, r := range rows {
+		named = append(named, &NamedRow{index: index, row: r})
+	}
+	return named
+}
+
+// FetchNamedRowsContext runs the query and returns its rows to be read by column name.
+func FetchNamedRowsContext(ctx context.Context, q Querier, querytext string, params ...interface{}) ([]*NamedRow, error) {
+	columns, rows, err := q.FetchRowsWithColumnsContext(ctx, querytext, params...)
+	if err != nil {
+		return nil, err
+	}
+	return NamedRows(columns, rows), nil
+}
+
+// Err returns the error of the first value which could not be read.
+func (r *NamedRow) Err() error {
+	return r.err
+}
+
+func (r *NamedRow) value(col string) (interface{}, bool) {
+	i, ok := r.index[col]
+	if !ok || i >= len(r.row) {
+		r.fail(col, errors.New("not in query result"))
+		return nil, false
+	}
+	return r.row[i], true
+}
+
+func (r *NamedRow) fail(col string, err error) {
+	if r.err == nil {
+		r.err = fmt.Errorf("column %s: %w", col, err)
+	}
+}
+
+// String returns the value of the column as text, NULL is the empty string.
+// Numbers are formatted without exponent, dates as yyyy-mm-dd hh24:mi:ss.
+func (r *NamedRow) String(col string) string {
+	v, ok := r.value(col)
+	if !ok {
+		return ""
+	}
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case sql.NullString:
+		return val.String
+	case sql.NullTime:
+		if !val.Valid {
+			return ""
+		}
+		return val.Time.Format("2006-01-02 15:04:05")
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	case []byte:
+		return fmt.Sprintf("%x", val)
+	case fmt.Stringer:
+		return val.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Float returns the value of the column as number, a NULL value or text which is no number is an error.
+func (r *NamedRow) Float(col string) float64 {
+	f, valid := r.NullFloat(col)
+	if !valid {
+		r.fail(col, ErrNull)
+	}
+	return f
+}
+
+// NullFloat returns the value of the column as number, valid is false if the value is NULL.
+func (r *NamedRow) NullFloat(col string) (f float64, valid bool) {
+	v, ok := r.value(col)
+	if !ok {
+		return 0, true
+	}
+	var err error
+	switch val := v.(type) {
+	case nil:
+		return 0, false
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case string:
+		f, err = strconv.ParseFloat(val, 64)
+	case sql.NullString:
+		if !val.Valid {
+			return 0, false
+		}
+		f, err = strconv.ParseFloat(val.String, 64)
+	case sql.NullFloat64:
+		return val.Float64, val.Valid
+	case fmt.Stringer:
+		f, err = strconv.ParseFloat(val.String(), 64)
+	default:
+		err = fmt.Errorf("can not read %T as number", v)
+	}
+	if err != nil {
+		r.fail(col, err)
+		return 0, true
+	}
+	return f, true
+}
@@ -0,0 +1,58 @@
+package dbutil
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNamedRow(t *testing.T) {
+	created := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	rows := NamedRows([]string{"name", "bytes", "max_bytes", "autoextensible", "created", "status"}, []Row{
+		{"SYSTEM", 1024.0, nil, sql.NullString{String: "YES", Valid: true}, sql.NullTime{Time: created, Valid: true}, "1.5"},
+		{"USERS", nil, nil, sql.NullString{}, sql.NullTime{}, "ONLINE"},
+	})
+
+	r := rows[0]
+	if got := r.String("name"); got != "SYSTEM" {
+		t.Errorf("name %q", got)
+	}
+	if got := r.String("bytes"); got != "1024" {
+		t.Errorf("bytes as string %q", got)
+	}
+	if got := r.Float("bytes"); got != 1024 {
+		t.Errorf("bytes %v", got)
+	}
+	if _, valid := r.NullFloat("max_bytes"); valid {
+		t.Error("max_bytes is NULL")
+	}
+	if got := r.String("autoextensible"); got != "YES" {
+		t.Errorf("autoextensible %q", got)
+	}
+	if got := r.String("created"); got != "2022-09-01 10:00:00" {
+		t.Errorf("created %q", got)
+	}
+	if got := r.Float("status"); got != 1.5 {
+		t.Errorf("status %v", got)
+	}
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+
+	r = rows[1]
+	if got := r.String("bytes") + r.String("autoextensible") + r.String("created"); got != "" {
+		t.Errorf("NULLs as string %q", got)
+	}
+	r.Float("bytes")
+	r.Float("status")
+	if !errors.Is(r.Err(), ErrNull) {
+		t.Errorf("error %v, want the first one of NULL bytes", r.Err())
+	}
+
+	r = rows[0]
+	r.String("missing")
+	if r.Err() == nil {
+		t.Error("no error for missing column")
+	}
+}
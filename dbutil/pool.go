@@ -0,0 +1,198 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pingTimeout = 5 * time.Second
+	minBackoff  = time.Second
+	maxBackoff  = 5 * time.Minute
+)
+
+// PoolStats describes the connection pool of one CDB/PDB service.
+type PoolStats struct {
+	Service    string
+	Connected  bool
+	Reconnects uint64
+	Failures   int
+	sql.DBStats
+}
+
+// poolSet holds the connection pools of a database, keyed by pdb name ("" is the CDB or non-CDB).
+// It is shared by the client of the database and the clients of its PDBs.
+type poolSet struct {
+	mu    sync.Mutex
+	pools map[string]*pool
+}
+
+type pool struct {
+	mu         sync.Mutex
+	service    string
+	db         *sql.DB
+	failures   int
+	nextRetry  time.Time
+	lastError  error
+	reconnects uint64
+}
+
+func newPoolSet() *poolSet {
+	return &poolSet{pools: make(map[string]*pool)}
+}
+
+func (s *poolSet) get(key string, service string) *pool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pools[key]
+	if !ok {
+		p = &pool{service: service}
+		s.pools[key] = p
+	}
+	return p
+}
+
+func (s *poolSet) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lastErr error
+	for key, p := range s.pools {
+		err := p.close()
+		if err != nil {
+			lastErr = err
+		}
+		delete(s.pools, key)
+	}
+	return lastErr
+}
+
+// retain closes and removes the pools whose key is not in keep.
+func (s *poolSet) retain(keep map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, p := range s.pools {
+		if keep[key] {
+			continue
+		}
+		log.WithFields(log.Fields{"service": p.service}).Info("Close Connection Pool")
+		err := p.close()
+		if err != nil {
+			log.WithFields(log.Fields{"service": p.service, "error": err}).Error("Close Connection Pool has error")
+		}
+		delete(s.pools, key)
+	}
+}
+
+func (s *poolSet) stats() []PoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ret []PoolStats
+	for _, p := range s.pools {
+		ret = append(ret, p.stats())
+	}
+	return ret
+}
+
+// validate makes sure the pool is connected, connecting with backoff when it is not,
+// and checks the connection with a ping. A pool whose ping fails, e.g. after a restart of the
+// database, is reconnected at once, the backoff only applies after a failed connect.
+func (p *pool) validate(ctx context.Context, connect func() (*sql.DB, error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	broken := false
+	if p.db != nil {
+		err := ping(ctx, p.db)
+		if err == nil {
+			return nil
+		}
+		log.WithFields(log.Fields{"service": p.service, "error": err}).Warn("Ping Failed, Reconnect")
+		p.db.Close()
+		p.db = nil
+		broken = true
+	}
+
+	if !broken && time.Now().Before(p.nextRetry) {
+		return fmt.Errorf("connect to %s backed off until %s, last error: %s",
+			p.service, p.nextRetry.Format(time.RFC3339), p.lastError)
+	}
+
+	db, err := connect()
+	if err != nil {
+		p.fail(err)
+		return err
+	}
+
+	err = ping(ctx, db)
+	if err != nil {
+		db.Close()
+		p.fail(err)
+		return err
+	}
+
+	p.db = db
+	p.failures = 0
+	p.lastError = nil
+	if broken {
+		p.reconnects++
+	}
+	return nil
+}
+
+func (p *pool) fail(err error) {
+	p.failures++
+	p.lastError = err
+
+	backoff := minBackoff
+	for i := 1; i < p.failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	p.nextRetry = time.Now().Add(backoff)
+}
+
+func (p *pool) conn() *sql.DB {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.db
+}
+
+func (p *pool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.db == nil {
+		return nil
+	}
+	err := p.db.Close()
+	p.db = nil
+	return err
+}
+
+func (p *pool) stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := PoolStats{Service: p.service, Connected: p.db != nil, Reconnects: p.reconnects, Failures: p.failures}
+	if p.db != nil {
+		s.DBStats = p.db.Stats()
+	}
+	return s
+}
+
+func ping(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
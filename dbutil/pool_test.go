@@ -0,0 +1,78 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+// pingConnector connects to a fake database whose ping returns err.
+type pingConnector struct {
+	err error
+}
+
+func (c pingConnector) Connect(context.Context) (driver.Conn, error) { return pingConn(c), nil }
+func (c pingConnector) Driver() driver.Driver                        { return nil }
+
+type pingConn struct {
+	err error
+}
+
+func (c pingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c pingConn) Close() error                        { return nil }
+func (c pingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (c pingConn) Ping(context.Context) error          { return c.err }
+
+func TestPoolBackoff(t *testing.T) {
+	p := &pool{service: "orcl"}
+	connectErr := errors.New("ORA-12514: TNS:listener does not currently know of service")
+	attempts := 0
+	connect := func() (*sql.DB, error) {
+		attempts++
+		return nil, connectErr
+	}
+
+	err := p.validate(context.Background(), connect)
+	if err != connectErr {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the next attempt is backed off, connect is not called again
+	err = p.validate(context.Background(), connect)
+	if err == nil || attempts != 1 {
+		t.Fatalf("connect not backed off, attempts: %d, error: %v", attempts, err)
+	}
+
+	for i := 0; i < 20; i++ {
+		p.fail(connectErr)
+	}
+	if wait := time.Until(p.nextRetry); wait > maxBackoff || wait < maxBackoff-time.Second {
+		t.Fatalf("backoff not capped: %s", wait)
+	}
+}
+
+func TestPoolReconnect(t *testing.T) {
+	// the connection of the pool is broken by a restart of the database
+	p := &pool{service: "orcl", db: sql.OpenDB(pingConnector{err: driver.ErrBadConn})}
+	attempts := 0
+	connect := func() (*sql.DB, error) {
+		attempts++
+		return sql.OpenDB(pingConnector{}), nil
+	}
+
+	if err := p.validate(context.Background(), connect); err != nil {
+		t.Fatalf("broken pool not reconnected: %s", err)
+	}
+	if attempts != 1 || p.reconnects != 1 || p.failures != 0 {
+		t.Fatalf("attempts %d, reconnects %d, failures %d", attempts, p.reconnects, p.failures)
+	}
+
+	// a good pool is not reconnected
+	if err := p.validate(context.Background(), connect); err != nil || attempts != 1 || p.reconnects != 1 {
+		t.Fatalf("good pool reconnected, attempts %d, error %v", attempts, err)
+	}
+	p.close()
+}
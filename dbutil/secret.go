@@ -0,0 +1,51 @@
+package dbutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var envPattern = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// ExpandEnv replaces ${NAME} in the string settings with the value of the environment variable NAME.
+// A plain $ is kept, so that it can be used in passwords.
+func (c *OracleConfig) ExpandEnv() error {
+	fields := []*string{&c.Dsn, &c.Host, &c.Username, &c.Password, &c.PasswordFile,
+		&c.ServiceName, &c.Sid, &c.ConfigDir}
+	for _, f := range fields {
+		v, err := expandEnv(*f)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
+}
+
+func expandEnv(s string) (string, error) {
+	var err error
+	ret := envPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := envPattern.FindStringSubmatch(m)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v
+	})
+	return ret, err
+}
+
+// GetPassword returns the password, or the content of the password file without the trailing newline.
+func (c *OracleConfig) GetPassword() (string, error) {
+	if c.PasswordFile == "" {
+		return c.Password, nil
+	}
+	buf, err := ioutil.ReadFile(c.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("read password file: %s", err)
+	}
+	return strings.TrimRight(string(buf), "\r\n"), nil
+}
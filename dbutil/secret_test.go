@@ -0,0 +1,56 @@
+package dbutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("ORACLEDB_EXPORTER_TEST_USER", "monitor")
+	defer os.Unsetenv("ORACLEDB_EXPORTER_TEST_USER")
+
+	c := OracleConfig{Username: "${ORACLEDB_EXPORTER_TEST_USER}", Password: "pa$$word"}
+	if err := c.ExpandEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Username != "monitor" || c.Password != "pa$$word" {
+		t.Fatalf("unexpected config: %+v", c)
+	}
+
+	c = OracleConfig{Password: "${ORACLEDB_EXPORTER_TEST_UNSET}"}
+	if err := c.ExpandEnv(); err == nil {
+		t.Fatal("unset variable expanded")
+	}
+}
+
+func TestConnectionParams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(file, []byte(`se"cr et`+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cli := NewOracleClient(OracleConfig{Dsn: "10.0.0.1:1521/orcl", Username: "monitor", PasswordFile: file})
+	params, err := cli.connectionParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Username != "monitor" || params.Password.Secret() != `se"cr et` || params.ExternalAuth {
+		t.Fatalf("unexpected params: %s", params.StringWithPassword())
+	}
+
+	cli = NewOracleClient(OracleConfig{Dsn: "orcl_alias", ExternalAuth: true, ConfigDir: dir})
+	params, err = cli.connectionParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Username != "" || !params.Password.IsZero() || !params.ExternalAuth || params.ConfigDir != dir || params.ConnectString != "orcl_alias" {
+		t.Fatalf("unexpected params: %s", params.StringWithPassword())
+	}
+}
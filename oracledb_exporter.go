@@ -4,16 +4,17 @@ import (
 	"os"
 
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
-	_ "net/http/pprof"
-
 	"yunche.pro/dtsre/oracledb_exporter/collector"
+	"yunche.pro/dtsre/oracledb_exporter/dbutil"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"yunche.pro/dtsre/oracledb_exporter/logutil"
@@ -25,6 +26,11 @@ var (
 		"Path under which to expose metrics.",
 	).Default("/metrics").String()
 
+	probePath = kingpin.Flag(
+		"web.probe-path",
+		"Path under which to expose metrics of the target given by the 'target' parameter.",
+	).Default("/probe").String()
+
 	listenAddress = kingpin.Flag(
 		"web.listen-address",
 		"Address to listen on for web interface and telemetry.",
@@ -34,10 +40,24 @@ var (
 		"Offset to subtract from timeout in seconds.",
 	).Default("0.25").Float64()
 
+	recordFile = kingpin.Flag(
+		"fixtures.record",
+		"Record the results of all queries into this file, to replay them later.",
+	).String()
+	replayFile = kingpin.Flag(
+		"fixtures.replay",
+		"Answer the queries from the results recorded in this file instead of connecting to Oracle.",
+	).String()
+
+	serveCmd = kingpin.Command("serve", "Serve the metrics, the default command.").Default()
+
 	configFile = kingpin.Flag("config", "exporter config file").Default("oracledb_exporter.yaml").String()
 	loglevel   = kingpin.Flag("level", "exporter log level").Default("info").String()
 )
 
+// targets keeps the connection pools of the configured databases across scrapes.
+var targets = collector.NewTargets()
+
 var scrapers = map[collector.Scraper]bool{
 	&collector.ScrapeOracleStat{}:             true,
 	&collector.ScrapeOracleWaitEvent{}:        true,
@@ -53,11 +73,23 @@ var scrapers = map[collector.Scraper]bool{
 	&collector.ScrapeOracleBackupInfo{}:       true,
 	&collector.ScrapeOracleAsmStat{}:          true,
 	&collector.ScrapeActiveTransactionStat{}:  true,
+	&collector.ScrapeOracleRac{}:              true,
 }
 
+// builtinScrapers returns the scrapers of the exporter, without the custom metrics of the config.
+func builtinScrapers() []collector.Scraper {
+	ret := make([]collector.Scraper, 0, len(scrapers))
+	for scraper := range scrapers {
+		ret = append(ret, scraper)
+	}
+	return ret
+}
+
+// scraperFlags are the --collect.<name> flags of the scrapers.
+var scraperFlags = map[collector.Scraper]*bool{}
+
 func main() {
 	// Generate ON/OFF flags for all scrapers.
-	scraperFlags := map[collector.Scraper]*bool{}
 	for scraper, enabledByDefault := range scrapers {
 		defaultOn := "false"
 		if enabledByDefault {
@@ -72,47 +104,156 @@ func main() {
 		scraperFlags[scraper] = f
 	}
 
-	kingpin.Parse()
+	command := kingpin.Parse()
 
 	logutil.InitLog("oracledb_exporter.log", *loglevel)
 
-	// landingPage contains the HTML served at '/'.
-	// TODO: Make this nicer and more informative.
-	var landingPage = []byte(`<html>
-<head><title>Oracle DB exporter</title></head>
-<body>
-<h1>Oracle DB exporter</h1>
-<p><a href='` + *metricPath + `'>Metrics</a></p>
-</body>
-</html>
-`)
+	if err := setupFixtures(); err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Setup Fixtures Failed")
+		os.Exit(1)
+	}
+
+	if command == checkCmd.FullCommand() {
+		runCheckConfig()
+		return
+	}
 
-	enabledScrapers := []collector.Scraper{}
 	for scraper, enabled := range scraperFlags {
 		if *enabled {
 			log.WithFields(log.Fields{"scraper": scraper.Name()}).Info("Scraper Enabled")
-			enabledScrapers = append(enabledScrapers, scraper)
 		}
 	}
 
-	handlerFunc := newHandler(collector.NewMetrics(), enabledScrapers)
-	log.WithFields(log.Fields{"metricPath": *metricPath}).Debug("handler for metricPath")
-	http.Handle(*metricPath, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handlerFunc))
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write(landingPage)
+	reloader := newConfigReloader(*configFile, targets)
+	if err := reloader.Reload(); err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Load Config Failed")
+		os.Exit(1)
+	}
+	go reloader.watchSignals()
+
+	// the endpoints are looked up by path in the current config, so that they can be changed by a reload
+	metricsHandler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, newHandler(reloader, false))
+	// not the default mux, which has the pprof handlers
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		cfg := reloader.Config()
+		if _, ok := endpointByPath(cfg, r.URL.Path); ok {
+			metricsHandler.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(landingPage(cfg))
 	})
 
+	log.WithFields(log.Fields{"probePath": *probePath}).Debug("handler for probePath")
+	mux.Handle(*probePath, newHandler(reloader, true))
+	mux.Handle("/-/reload", reloader)
+
+	if err := web.Validate(*webConfigFile); err != nil {
+		log.WithFields(log.Fields{"error": err, "file": *webConfigFile}).Error("Invalid Web Config")
+		os.Exit(1)
+	}
+	if *pprofAddress != "" {
+		go func() {
+			log.WithFields(log.Fields{"address": *pprofAddress}).Info("Listening on pprof address")
+			if err := listenAndServe(*pprofAddress, newPprofMux()); err != nil {
+				log.WithFields(log.Fields{"err": err}).Error("Error starting pprof HTTP server")
+				os.Exit(1)
+			}
+		}()
+	}
+
 	log.WithFields(log.Fields{"address": *listenAddress}).Info("Listening on address")
-	srv := &http.Server{Addr: *listenAddress}
-	if err := srv.ListenAndServe(); err != nil {
+	if err := listenAndServe(*listenAddress, mux); err != nil {
 		log.WithFields(log.Fields{"err": err}).Error("Error starting HTTP server")
 		os.Exit(1)
 	}
 }
 
-func newHandler(metrics collector.Metrics, scrapers []collector.Scraper) http.HandlerFunc {
+// setupFixtures enables recording or replaying of the query results.
+func setupFixtures() error {
+	switch {
+	case *recordFile != "" && *replayFile != "":
+		return fmt.Errorf("--fixtures.record and --fixtures.replay can not be used together")
+	case *recordFile != "":
+		log.WithFields(log.Fields{"file": *recordFile}).Info("Record Queries")
+		dbutil.Record(dbutil.NewFixtures(*recordFile))
+	case *replayFile != "":
+		f, err := dbutil.LoadFixtures(*replayFile)
+		if err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{"file": *replayFile}).Info("Replay Queries")
+		dbutil.Replay(f)
+	}
+	return nil
+}
+
+// landingPage returns the HTML served at '/'.
+// TODO: Make this nicer and more informative.
+func landingPage(cfg *collector.Config) []byte {
+	var links string
+	for _, ep := range endpoints(cfg) {
+		links += "<p><a href='" + ep.Path + "'>Metrics " + ep.Name + "</a></p>\n"
+	}
+	return []byte(`<html>
+<head><title>Oracle DB exporter</title></head>
+<body>
+<h1>Oracle DB exporter</h1>
+` + links + `<p>Probe a configured target: <code>` + *probePath + `?target=&lt;name&gt;&amp;endpoint=&lt;endpoint&gt;</code></p>
+</body>
+</html>
+`)
+}
+
+// endpoints returns the endpoints of the config, or the telemetry path if none is configured.
+func endpoints(cfg *collector.Config) []collector.Endpoint {
+	if len(cfg.Endpoints) > 0 {
+		return cfg.Endpoints
+	}
+	return []collector.Endpoint{{Path: *metricPath}}
+}
+
+func endpointByPath(cfg *collector.Config, path string) (collector.Endpoint, bool) {
+	for _, ep := range endpoints(cfg) {
+		if ep.Path == path {
+			return ep, true
+		}
+	}
+	return collector.Endpoint{}, false
+}
+
+// newHandler serves the collectors of the endpoint of the request path for the default database of the config file,
+// or with probe set the named database given by the 'target' parameter and the endpoint given by the 'endpoint' parameter.
+func newHandler(reloader *configReloader, probe bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		filteredScrapers := scrapers
+		cfg := reloader.Config()
+		target, status, err := lookupTarget(r, probe)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err, "url": r.URL.String()}).Error("Lookup Target Failed")
+			http.Error(w, err.Error(), status)
+			return
+		}
+		defer target.Release()
+
+		var endpointName string
+		if probe {
+			endpointName = r.URL.Query().Get("endpoint")
+		} else {
+			ep, _ := endpointByPath(cfg, r.URL.Path)
+			endpointName = ep.Name
+		}
+		enabledScrapers, err := endpointScrapers(cfg, endpointName)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err, "url": r.URL.String()}).Error("Lookup Endpoint Failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filteredScrapers := enabledScrapers
 		params := r.URL.Query()["collect[]"]
 		// Use request context for cancellation when connection gets closed.
 		ctx := r.Context()
@@ -146,7 +287,7 @@ func newHandler(metrics collector.Metrics, scrapers []collector.Scraper) http.Ha
 			}
 
 			filteredScrapers = nil
-			for _, scraper := range scrapers {
+			for _, scraper := range enabledScrapers {
 				if filters[scraper.Name()] {
 					filteredScrapers = append(filteredScrapers, scraper)
 				}
@@ -154,14 +295,82 @@ func newHandler(metrics collector.Metrics, scrapers []collector.Scraper) http.Ha
 		}
 
 		registry := prometheus.NewRegistry()
-		registry.MustRegister(collector.New(ctx, filteredScrapers, *configFile))
+		registry.MustRegister(collector.New(ctx, filteredScrapers, target, cfg))
 
-		gatherers := prometheus.Gatherers{
-			prometheus.DefaultGatherer,
-			registry,
+		gatherers := prometheus.Gatherers{registry}
+		if !probe {
+			gatherers = append(gatherers, prometheus.DefaultGatherer)
 		}
 		// Delegate http serving to Prometheus client library, which will call collector.Collect.
 		h := promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
 		h.ServeHTTP(w, r)
 	}
 }
+
+// lookupTarget returns the target to scrape, or the http status to reply if the target can not be found.
+func lookupTarget(r *http.Request, probe bool) (*collector.Target, int, error) {
+	name := ""
+	if probe {
+		name = r.URL.Query().Get("target")
+		if name == "" {
+			return nil, http.StatusBadRequest, fmt.Errorf("'target' parameter must be specified")
+		}
+	}
+
+	target, ok := targets.Get(name)
+	if !ok {
+		return nil, http.StatusBadRequest, fmt.Errorf("unknown target %q", name)
+	}
+	return target, http.StatusOK, nil
+}
+
+// endpointScrapers returns the scrapers of the named endpoint, or the enabled scrapers
+// if the endpoint does not list collectors. Collectors disabled in the config are left out.
+func endpointScrapers(cfg *collector.Config, endpoint string) ([]collector.Scraper, error) {
+	custom := cfg.CustomScrapers()
+
+	var names []string
+	if endpoint != "" {
+		ep, ok := cfg.Endpoint(endpoint)
+		if !ok {
+			return nil, fmt.Errorf("unknown endpoint %q", endpoint)
+		}
+		names = ep.Collectors
+	}
+	if len(names) == 0 {
+		var ret []collector.Scraper
+		for scraper, enabled := range scraperFlags {
+			if cfg.CollectorEnabled(scraper.Name(), *enabled) {
+				ret = append(ret, scraper)
+			}
+		}
+		for _, scraper := range custom {
+			if cfg.CollectorEnabled(scraper.Name(), true) {
+				ret = append(ret, scraper)
+			}
+		}
+		return ret, nil
+	}
+
+	all := make(map[string]collector.Scraper)
+	for scraper := range scrapers {
+		all[scraper.Name()] = scraper
+	}
+	for _, scraper := range custom {
+		all[scraper.Name()] = scraper
+	}
+
+	var ret []collector.Scraper
+	for _, name := range names {
+		scraper, ok := all[name]
+		if !ok {
+			log.WithFields(log.Fields{"endpoint": endpoint, "collector": name}).Warn("Unknown Collector")
+			continue
+		}
+		if !cfg.CollectorEnabled(name, true) {
+			continue
+		}
+		ret = append(ret, scraper)
+	}
+	return ret, nil
+}
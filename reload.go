@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"yunche.pro/dtsre/oracledb_exporter/collector"
+)
+
+var (
+	configReloadSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "oracle",
+		Subsystem: "exporter",
+		Name:      "config_last_reload_successful",
+		Help:      "Whether the last configuration reload attempt was successful.",
+	})
+	configReloadSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "oracle",
+		Subsystem: "exporter",
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Timestamp of the last successful configuration reload.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(configReloadSuccess, configReloadSeconds)
+}
+
+// configReloader holds the config in use. A reload parses and validates the whole config file
+// before swapping it in, an invalid file keeps the old config.
+type configReloader struct {
+	file    string
+	targets *collector.Targets
+
+	// mu serializes reloads
+	mu  sync.Mutex
+	cfg atomic.Value
+}
+
+func newConfigReloader(file string, targets *collector.Targets) *configReloader {
+	return &configReloader{file: file, targets: targets}
+}
+
+// Config returns the config in use, nil before the first successful reload.
+func (r *configReloader) Config() *collector.Config {
+	cfg, _ := r.cfg.Load().(*collector.Config)
+	return cfg
+}
+
+// Reload reads the config file and swaps it in if it is valid.
+func (r *configReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := collector.LoadConfig(r.file)
+	if err == nil {
+		err = cfg.ValidateCollectors(builtinScrapers())
+	}
+	if err != nil {
+		configReloadSuccess.Set(0)
+		return fmt.Errorf("load config %s: %s", r.file, err)
+	}
+
+	r.targets.Update(cfg)
+	r.cfg.Store(cfg)
+
+	configReloadSuccess.Set(1)
+	configReloadSeconds.SetToCurrentTime()
+	log.WithFields(log.Fields{"config": r.file}).Info("Config Loaded")
+	return nil
+}
+
+// watchSignals reloads the config on SIGHUP.
+func (r *configReloader) watchSignals() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := r.Reload(); err != nil {
+			log.WithFields(log.Fields{"error": err}).Error("Reload Config Failed")
+		}
+	}
+}
+
+// ServeHTTP reloads the config on POST /-/reload.
+func (r *configReloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.Reload(); err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Reload Config Failed")
+		http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yunche.pro/dtsre/oracledb_exporter/collector"
+)
+
+func TestConfigReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "oracledb_exporter.yaml")
+
+	write := func(content string) {
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	targets := collector.NewTargets()
+	r := newConfigReloader(file, targets)
+
+	write("serviceName: orcl\ntargets:\n  - name: db1\n")
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	target, ok := targets.Get("db1")
+	if !ok {
+		t.Fatal("target db1 not found")
+	}
+	target.Release()
+
+	// an invalid config keeps the old one
+	write("serviceName: orcl\ntargets:\n  - name: db1\n  - name: db1\n")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("reload invalid config: status %d", w.Code)
+	}
+	if cfg := r.Config(); cfg == nil || len(cfg.Targets) != 1 {
+		t.Fatalf("config after failed reload: %+v", cfg)
+	}
+
+	// so does an unknown collector
+	write("serviceName: orcl\ntargets:\n  - name: db1\ncollectors:\n  oracle_tablespaces:\n    interval: 1h\n")
+	if err := r.Reload(); err == nil || !strings.Contains(err.Error(), `unknown collector "oracle_tablespaces"`) {
+		t.Fatalf("reload with unknown collector: %v", err)
+	}
+
+	write("serviceName: orcl\ntargets:\n  - name: db2\n")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("reload by GET: status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("reload: status %d %s", w.Code, w.Body)
+	}
+	if _, ok := targets.Get("db1"); ok {
+		t.Fatal("removed target db1 found")
+	}
+	target, ok = targets.Get("db2")
+	if !ok {
+		t.Fatal("target db2 not found")
+	}
+	target.Release()
+}
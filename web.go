@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/prometheus/exporter-toolkit/web"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	webConfigFile = kingpin.Flag(
+		"web.config.file",
+		"Path to the web config file enabling TLS, client certificate and basic authentication, in the format of the prometheus exporter-toolkit.",
+	).Default("").String()
+	pprofAddress = kingpin.Flag(
+		"web.pprof-address",
+		"Address of a separate listener serving the pprof profiles under /debug/pprof/, disabled if empty. It uses the web config file, too.",
+	).Default("").String()
+)
+
+// newPprofMux returns the handlers of net/http/pprof, which registers them on the
+// default mux as well, so that the default mux must not be served.
+func newPprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// listenAndServe serves handler on address, with TLS and basic authentication of the web config file if set.
+func listenAndServe(address string, handler http.Handler) error {
+	srv := &http.Server{Addr: address, Handler: handler}
+	return web.ListenAndServe(srv, *webConfigFile, kitLogger{})
+}
+
+// kitLogger logs the go-kit key values of the exporter-toolkit with logrus.
+type kitLogger struct{}
+
+func (kitLogger) Log(keyvals ...interface{}) error {
+	fields := log.Fields{}
+	msg := ""
+	level := log.InfoLevel
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		switch key {
+		case "msg":
+			msg = fmt.Sprint(keyvals[i+1])
+		case "level":
+			if l, err := log.ParseLevel(fmt.Sprint(keyvals[i+1])); err == nil {
+				level = l
+			}
+		default:
+			fields[key] = keyvals[i+1]
+		}
+	}
+	log.WithFields(fields).Log(level, msg)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+func TestWebConfigBasicAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracledb_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "web.yml")
+	// the bcrypt hash of "secret"
+	err = ioutil.WriteFile(file, []byte("basic_auth_users:\n  prometheus: $2a$04$3mNywXRNXcXMvGS8FTa7beB9P07/.hwUJzaltthEOfalyXi8wF1Y.\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {})
+	srv := &http.Server{Handler: mux}
+	go web.Serve(l, srv, file, kitLogger{})
+	defer srv.Close()
+
+	url := "http://" + l.Addr().String() + "/metrics"
+	cases := []struct {
+		user, password string
+		status         int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"prometheus", "wrong", http.StatusUnauthorized},
+		{"prometheus", "secret", http.StatusOK},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		if c.user != "" {
+			req.SetBasicAuth(c.user, c.password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.status {
+			t.Errorf("user %q password %q: status %d, want %d", c.user, c.password, resp.StatusCode, c.status)
+		}
+	}
+}
+
+func TestPprofMux(t *testing.T) {
+	w := httptest.NewRecorder()
+	newPprofMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("pprof index: status %d", w.Code)
+	}
+}